@@ -1,22 +1,11 @@
 package main
 
 import (
-	"fmt"
-	"os"
-	"strings"
-
-	"github.com/knadh/koanf/parsers/yaml"
-	"github.com/knadh/koanf/providers/env/v2"
-	"github.com/knadh/koanf/providers/file"
-	"github.com/knadh/koanf/providers/posflag"
-	"github.com/knadh/koanf/providers/rawbytes"
-	"github.com/knadh/koanf/v2"
-	flag "github.com/spf13/pflag"
-
 	"github.com/tuanvumaihuynh/victoria-o11y-lab/internal/http"
 	"github.com/tuanvumaihuynh/victoria-o11y-lab/internal/log"
 	"github.com/tuanvumaihuynh/victoria-o11y-lab/internal/postgres"
 	"github.com/tuanvumaihuynh/victoria-o11y-lab/internal/telemetry"
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/pkg/config"
 
 	_ "embed"
 )
@@ -24,93 +13,36 @@ import (
 //go:embed config.yml
 var defaultConfigBytes []byte
 
-type Config struct {
-	Log      log.Config       `yaml:"log"`
-	Postgres postgres.Config  `yaml:"postgres"`
-	HTTP     http.Config      `yaml:"http"`
-	Otel     telemetry.Config `yaml:"otel"`
-}
-
-func (c *Config) Validate() error {
-	if err := c.Log.Validate(); err != nil {
-		return fmt.Errorf("log: %w", err)
-	}
-
-	if err := c.Postgres.Validate(); err != nil {
-		return fmt.Errorf("postgres: %w", err)
-	}
-
-	if err := c.HTTP.Validate(); err != nil {
-		return fmt.Errorf("http: %w", err)
-	}
-
-	if err := c.Otel.Validate(); err != nil {
-		return fmt.Errorf("otel: %w", err)
-	}
-
-	return nil
+// reloadableKeys lists the config keys that can be changed in the config file
+// without restarting the process.
+var reloadableKeys = []string{
+	"log.level",
+	"log.format",
+	"log.sampling",
+	"log.outputs",
+	"log.naming",
+	"log.rename",
+	"log.stream_fields",
+	"otel.trace_id_ratio",
+	"http.cors.allowed_origins",
+	"http.chaos.enabled",
+	"http.chaos.rules",
 }
 
-// NewConfig loads and merges configuration from the embedded default,
-// flags, optional external config file, and environment variables (in this order).
-// It returns the final Config struct or an error if loading or unmarshalling fails.
-func NewConfig() (*Config, error) {
-	k := koanf.New(".")
-
-	if err := k.Load(rawbytes.Provider(defaultConfigBytes), yaml.Parser()); err != nil {
-		return nil, fmt.Errorf("load default config: %w", err)
-	}
-
-	if err := initFlags(k); err != nil {
-		return nil, fmt.Errorf("init flags: %w", err)
-	}
-
-	configFilePath := k.String("config")
-	if configFilePath != "" {
-		if err := k.Load(file.Provider(configFilePath), yaml.Parser()); err != nil {
-			return nil, fmt.Errorf("load config file: %w", err)
-		}
-	}
-
-	// Load environment variables and merge into the loaded config.
-	// API_foo__bar -> foo.bar (double underscore becomes dot for nested config)
-	if err := k.Load(env.Provider(".", env.Opt{
-		Prefix: "API_",
-		TransformFunc: func(k, v string) (string, any) {
-			key := strings.ToLower(strings.TrimPrefix(k, "API_"))
-			key = strings.ReplaceAll(key, "__", ".")
-			return key, v
-		},
-	}), nil); err != nil {
-		return nil, fmt.Errorf("load env: %w", err)
-	}
-
-	var cfg Config
-	if err := k.UnmarshalWithConf("", &cfg, koanf.UnmarshalConf{
-		Tag: "yaml",
-	}); err != nil {
-		return nil, fmt.Errorf("unmarshal config: %w", err)
-	}
-
-	return &cfg, nil
+type Config struct {
+	Log         log.Config            `yaml:"log"`
+	Postgres    postgres.Config       `yaml:"postgres"`
+	SchemaCheck postgres.VerifyConfig `yaml:"schema_check"`
+	HTTP        http.Config           `yaml:"http"`
+	Otel        telemetry.Config      `yaml:"otel"`
 }
 
-func initFlags(k *koanf.Koanf) error {
-	f := flag.NewFlagSet("api", flag.ContinueOnError)
-	f.Usage = func() {
-		fmt.Println(f.FlagUsages())
-		os.Exit(0)
-	}
-
-	f.String("config", "", "path to config file, if not provided, the default configuration will be used")
-
-	if err := f.Parse(os.Args[1:]); err != nil {
-		return fmt.Errorf("parse flags: %w", err)
-	}
-
-	if err := k.Load(posflag.Provider(f, ".", k), nil); err != nil {
-		return fmt.Errorf("load flags: %w", err)
-	}
-
-	return nil
+// newConfigLoader returns the loader of the api configuration.
+// Environment variables are prefixed with API_, e.g. API_HTTP__PORT -> http.port.
+func newConfigLoader() *config.Loader[Config] {
+	return config.NewLoader[Config](config.Options{
+		Name:      "api",
+		EnvPrefix: "API_",
+		Defaults:  defaultConfigBytes,
+	})
 }
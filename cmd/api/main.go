@@ -2,14 +2,18 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 
 	"github.com/tuanvumaihuynh/victoria-o11y-lab/internal/http"
 	"github.com/tuanvumaihuynh/victoria-o11y-lab/internal/log"
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/internal/postgres"
 	"github.com/tuanvumaihuynh/victoria-o11y-lab/internal/telemetry"
 	"github.com/tuanvumaihuynh/victoria-o11y-lab/pkg/cmdutil"
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/pkg/config"
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/pkg/redact"
 )
 
 func main() {
@@ -23,21 +27,26 @@ func run() error {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	cfg, err := NewConfig()
-	if err != nil {
-		return fmt.Errorf("new config: %w", err)
+	configLoader := newConfigLoader()
+	cfg, err := configLoader.Load(os.Args[1:])
+	if errors.Is(err, config.ErrExit) || errors.Is(err, config.ErrHelp) {
+		return nil
 	}
-
-	if err := cfg.Validate(); err != nil {
-		return fmt.Errorf("validate config: %w", err)
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
 	}
 
-	logger, err := log.NewLogger(cfg.Log)
+	logger, cleanupLogger, err := log.NewLogger(cfg.Log)
 	if err != nil {
 		return fmt.Errorf("new logger: %w", err)
 	}
+	defer func() {
+		if err := cleanupLogger(); err != nil {
+			fmt.Printf("error closing log outputs: %v\n", err)
+		}
+	}()
 
-	cleanupTracer, err := telemetry.InitTracer(ctx, cfg.Otel)
+	cleanupTracer, err := telemetry.InitTracer(ctx, cfg.Otel, redact.New(cfg.Log.Redact))
 	if err != nil {
 		return fmt.Errorf("init tracer: %w", err)
 	}
@@ -50,6 +59,20 @@ func run() error {
 	interruptChan := cmdutil.InterruptChan()
 
 	svc := http.New(cfg.HTTP, logger)
+
+	if cfg.SchemaCheck.Enabled {
+		router, err := postgres.NewRouter(ctx, cfg.Postgres, logger)
+		if err != nil {
+			return fmt.Errorf("new postgres router: %w", err)
+		}
+		defer router.Close()
+
+		check, err := checkSchema(ctx, logger, router, cfg.SchemaCheck)
+		if err != nil {
+			return err
+		}
+		svc.AddReadinessCheck("schema", check)
+	}
 	cleanup, err := svc.Run(ctx)
 	if err != nil {
 		panic(fmt.Errorf("error running http service: %w", err))
@@ -57,6 +80,28 @@ func run() error {
 
 	logger.InfoContext(ctx, "http service started", slog.String("addr", fmt.Sprintf(":%d", cfg.HTTP.Port)))
 
+	if watcher := config.NewWatcher(configLoader, logger, reloadableKeys...); watcher != nil {
+		watcher.Subscribe(func(cfg *Config) error {
+			return log.Reload(logger, cfg.Log)
+		})
+		watcher.Subscribe(func(cfg *Config) error {
+			telemetry.SetTraceIDRatio(cfg.Otel.TraceIDRatio)
+			return nil
+		})
+		watcher.Subscribe(func(cfg *Config) error {
+			return svc.Reload(cfg.HTTP)
+		})
+
+		if err := watcher.Watch(); err != nil {
+			return fmt.Errorf("watch config file: %w", err)
+		}
+		defer func() {
+			if err := watcher.Close(); err != nil {
+				logger.ErrorContext(ctx, "error closing config watcher", slog.Any("error", err))
+			}
+		}()
+	}
+
 	<-interruptChan
 
 	logger.InfoContext(ctx, "http service is shutting down")
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/internal/http"
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/internal/postgres"
+)
+
+// checkSchema checks the primary database for drift from the embedded migrations and logs the findings.
+// It fails when drift is found and cfg.FailOnDrift is set, and otherwise returns
+// a readiness check that repeats the verification on a read replica.
+func checkSchema(ctx context.Context, logger *slog.Logger, router *postgres.Router, cfg postgres.VerifyConfig) (http.ReadinessCheck, error) {
+	opts := postgres.VerifyOptions{Schema: cfg.Schema}
+
+	report, err := postgres.Verify(ctx, router.Primary(), opts)
+	if err != nil {
+		return nil, fmt.Errorf("verify schema: %w", err)
+	}
+
+	for _, f := range report.Findings {
+		logger.WarnContext(ctx, "schema drift detected",
+			slog.String("kind", f.Kind),
+			slog.Int64("version", f.Version),
+			slog.String("table", f.Table),
+			slog.String("message", f.Message),
+		)
+	}
+	if cfg.FailOnDrift && !report.OK() {
+		return nil, report.Err()
+	}
+
+	return func(ctx context.Context) error {
+		report, err := postgres.Verify(ctx, router.Reader(), opts)
+		if err != nil {
+			return err
+		}
+		return report.Err()
+	}, nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/internal/loadgen"
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/internal/log"
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/internal/telemetry"
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/pkg/config"
+
+	_ "embed"
+)
+
+//go:embed config.yml
+var defaultConfigBytes []byte
+
+type Config struct {
+	Log      log.Config       `yaml:"log"`
+	Otel     telemetry.Config `yaml:"otel"`
+	Scenario loadgen.Scenario `yaml:"scenario"`
+}
+
+// newConfigLoader returns the loader of the loadgen configuration.
+// Environment variables are prefixed with LOADGEN_, e.g. LOADGEN_SCENARIO__RPS -> scenario.rps.
+// A scenario file such as cmd/loadgen/scenarios/spike.yml is given with --config.
+func newConfigLoader() *config.Loader[Config] {
+	return config.NewLoader[Config](config.Options{
+		Name:      "loadgen",
+		EnvPrefix: "LOADGEN_",
+		Defaults:  defaultConfigBytes,
+		Usage:     "Usage: loadgen [--config <scenario file>] [flags]",
+	})
+}
@@ -0,0 +1,74 @@
+// Command loadgen sends a weighted mix of API requests at a target rate,
+// to produce logs, metrics and traces for the observability stack.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/internal/loadgen"
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/internal/log"
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/internal/telemetry"
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/pkg/cmdutil"
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/pkg/config"
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/pkg/redact"
+)
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Printf("error running load generator: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cfg, err := newConfigLoader().Load(os.Args[1:])
+	if errors.Is(err, config.ErrExit) || errors.Is(err, config.ErrHelp) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+
+	logger, cleanupLogger, err := log.NewLogger(cfg.Log)
+	if err != nil {
+		return fmt.Errorf("new logger: %w", err)
+	}
+	defer func() {
+		if err := cleanupLogger(); err != nil {
+			fmt.Printf("error closing log outputs: %v\n", err)
+		}
+	}()
+
+	cleanupTracer, err := telemetry.InitTracer(ctx, cfg.Otel, redact.New(cfg.Log.Redact))
+	if err != nil {
+		return fmt.Errorf("init tracer: %w", err)
+	}
+	defer func() {
+		if err := cleanupTracer(context.WithoutCancel(ctx)); err != nil {
+			logger.ErrorContext(ctx, "error cleaning up tracer", slog.Any("error", err))
+		}
+	}()
+
+	// Stop sending on interrupt, but still print the summary of what was sent.
+	interruptChan := cmdutil.InterruptChan()
+	go func() {
+		<-interruptChan
+		cancel()
+	}()
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = cfg.Scenario.Concurrency
+	client := &http.Client{Transport: transport}
+
+	summary := loadgen.NewRunner(cfg.Scenario, client, logger).Run(ctx)
+
+	return summary.Write(os.Stdout)
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pressly/goose/v3"
+
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/internal/postgres"
+)
+
+const usage = `Usage: migrate [flags] [command]
+
+Commands:
+  up            apply all pending migrations (default)
+  up-to VERSION apply pending migrations up to and including VERSION
+  down          roll back the most recently applied migration
+  down-to VERSION
+                roll back all migrations down to, but not including, VERSION
+  redo          roll back the most recently applied migration and apply it again
+  status        print the state of every migration, exits with code 3 if any are pending
+  version       print the version of the most recently applied migration
+  verify        check the database for drift from the embedded migrations, exits with code 4
+                if any is found; --schema also compares the tables against their definition
+  create NAME   create a new SQL migration file in --dir
+  seed          generate --count users from --seed and insert them in batches of --batch-size,
+                --mode upsert updates existing users, --mode truncate deletes all users first
+
+Concurrent runs are serialized with a Postgres advisory lock, see migration.lock_timeout.
+
+Flags:`
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+var (
+	// errPendingMigrations is returned by the status command when migrations are pending.
+	errPendingMigrations = errors.New("there are pending migrations")
+	// errSchemaDrift is returned by the verify command when drift is found.
+	errSchemaDrift = errors.New("schema drift detected")
+)
+
+// options are the flags of the subcommands run against the database.
+type options struct {
+	output string
+	schema bool
+}
+
+// command is a parsed subcommand with its arguments.
+type command struct {
+	name string
+	args []string
+}
+
+// parseCommand returns the subcommand of args, defaulting to up.
+func parseCommand(args []string) (command, error) {
+	if len(args) == 0 {
+		return command{name: "up"}, nil
+	}
+
+	cmd := command{name: args[0], args: args[1:]}
+
+	wantArgs := 0
+	switch cmd.name {
+	case "up", "down", "redo", "status", "version", "verify", "seed":
+	case "up-to", "down-to", "create":
+		wantArgs = 1
+	default:
+		return command{}, fmt.Errorf("unknown command %q", cmd.name)
+	}
+	if len(cmd.args) != wantArgs {
+		return command{}, fmt.Errorf("command %s expects %d argument(s), got %d", cmd.name, wantArgs, len(cmd.args))
+	}
+
+	return cmd, nil
+}
+
+// migrates reports whether cmd applies or rolls back migrations.
+func (c command) migrates() bool {
+	switch c.name {
+	case "up", "up-to", "down", "down-to", "redo":
+		return true
+	default:
+		return false
+	}
+}
+
+// version parses the version argument of cmd.
+func (c command) version() (int64, error) {
+	v, err := strconv.ParseInt(c.args[0], 10, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid version %q", c.args[0])
+	}
+
+	return v, nil
+}
+
+// runMigrator runs cmd against the database.
+func runMigrator(ctx context.Context, logger *slog.Logger, m *postgres.Migrator, cmd command, opts options) error {
+	var (
+		results []*goose.MigrationResult
+		err     error
+	)
+
+	switch cmd.name {
+	case "up":
+		results, err = m.Up(ctx)
+	case "up-to":
+		v, verr := cmd.version()
+		if verr != nil {
+			return verr
+		}
+		results, err = m.UpTo(ctx, v)
+	case "down":
+		results, err = m.Down(ctx)
+	case "down-to":
+		v, verr := cmd.version()
+		if verr != nil {
+			return verr
+		}
+		results, err = m.DownTo(ctx, v)
+	case "redo":
+		results, err = m.Redo(ctx)
+	case "status":
+		return printStatus(ctx, os.Stdout, m, opts.output)
+	case "version":
+		v, verr := m.Version(ctx)
+		if verr != nil {
+			return fmt.Errorf("get version: %w", verr)
+		}
+		fmt.Println(v)
+		return nil
+	case "verify":
+		return printVerify(ctx, os.Stdout, m, opts)
+	}
+
+	if partialErr, ok := errors.AsType[*goose.PartialError](err); ok {
+		results = append(results, partialErr.Applied...)
+		results = append(results, partialErr.Failed)
+	}
+	// Applied migrations are logged by goose, only failures are logged here.
+	for _, r := range results {
+		if r.Error != nil {
+			logger.ErrorContext(ctx, "migration failed",
+				slog.Int64("version", r.Source.Version),
+				slog.String("source", r.Source.Path),
+				slog.String("direction", r.Direction),
+				slog.Duration("duration", r.Duration),
+				slog.Any("error", r.Error),
+			)
+		}
+	}
+	if errors.Is(err, goose.ErrNoNextVersion) || (err == nil && len(results) == 0) {
+		logger.InfoContext(ctx, "no migrations to apply")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", cmd.name, err)
+	}
+
+	return nil
+}
+
+// plannedMigration is the JSON representation of a [postgres.PlannedMigration].
+type plannedMigration struct {
+	Version int64  `json:"version"`
+	Source  string `json:"source"`
+	SQL     string `json:"sql"`
+}
+
+// printPlan writes the migrations that cmd would apply, and their SQL, to w.
+func printPlan(ctx context.Context, w io.Writer, m *postgres.Migrator, cmd command, output string) error {
+	target := int64(math.MaxInt64)
+	if cmd.name == "up-to" {
+		v, err := cmd.version()
+		if err != nil {
+			return err
+		}
+		target = v
+	}
+
+	plan, err := m.Plan(ctx, target)
+	if err != nil {
+		return fmt.Errorf("plan: %w", err)
+	}
+
+	if output == outputJSON {
+		rows := make([]plannedMigration, 0, len(plan))
+		for _, p := range plan {
+			rows = append(rows, plannedMigration{Version: p.Version, Source: p.Path, SQL: p.SQL})
+		}
+
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(rows); err != nil {
+			return fmt.Errorf("encode plan: %w", err)
+		}
+		return nil
+	}
+
+	if len(plan) == 0 {
+		fmt.Fprintln(w, "no migrations to apply")
+		return nil
+	}
+	for _, p := range plan {
+		fmt.Fprintf(w, "-- %d %s\n%s\n\n", p.Version, p.Path, p.SQL)
+	}
+
+	return nil
+}
+
+// printVerify writes the drift report of the database to w and returns
+// errSchemaDrift if any drift is found.
+func printVerify(ctx context.Context, w io.Writer, m *postgres.Migrator, opts options) error {
+	report, err := m.Verify(ctx, postgres.VerifyOptions{Schema: opts.schema})
+	if err != nil {
+		return fmt.Errorf("verify: %w", err)
+	}
+
+	switch opts.output {
+	case outputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return fmt.Errorf("encode report: %w", err)
+		}
+	default:
+		fmt.Fprintf(w, "checked: %s\n", strings.Join(report.Checked, ", "))
+		if report.OK() {
+			fmt.Fprintln(w, "no drift found")
+			break
+		}
+
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "KIND\tMESSAGE")
+		for _, f := range report.Findings {
+			fmt.Fprintf(tw, "%s\t%s\n", f.Kind, f.Message)
+		}
+		if err := tw.Flush(); err != nil {
+			return fmt.Errorf("write report: %w", err)
+		}
+	}
+
+	if !report.OK() {
+		return errSchemaDrift
+	}
+
+	return nil
+}
+
+// migrationStatus is the JSON representation of a [goose.MigrationStatus].
+type migrationStatus struct {
+	Version   int64      `json:"version"`
+	Source    string     `json:"source"`
+	State     string     `json:"state"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// printStatus writes the state of every migration to w and returns
+// errPendingMigrations if any migration is pending.
+func printStatus(ctx context.Context, w io.Writer, m *postgres.Migrator, output string) error {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return fmt.Errorf("status: %w", err)
+	}
+
+	rows := make([]migrationStatus, 0, len(statuses))
+	pending := false
+	for _, s := range statuses {
+		row := migrationStatus{
+			Version: s.Source.Version,
+			Source:  s.Source.Path,
+			State:   string(s.State),
+		}
+		if s.State == goose.StateApplied {
+			row.AppliedAt = &s.AppliedAt
+		} else {
+			pending = true
+		}
+		rows = append(rows, row)
+	}
+
+	switch output {
+	case outputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(rows); err != nil {
+			return fmt.Errorf("encode status: %w", err)
+		}
+	default:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tSTATE\tAPPLIED AT\tSOURCE")
+		for _, r := range rows {
+			appliedAt := "-"
+			if r.AppliedAt != nil {
+				appliedAt = r.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", r.Version, r.State, appliedAt, r.Source)
+		}
+		if err := tw.Flush(); err != nil {
+			return fmt.Errorf("write status: %w", err)
+		}
+	}
+
+	if pending {
+		return errPendingMigrations
+	}
+
+	return nil
+}
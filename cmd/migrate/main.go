@@ -2,15 +2,38 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 
+	"github.com/prometheus/client_golang/prometheus"
+
 	"github.com/tuanvumaihuynh/victoria-o11y-lab/internal/log"
 	"github.com/tuanvumaihuynh/victoria-o11y-lab/internal/postgres"
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/internal/telemetry"
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/pkg/config"
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/pkg/redact"
+)
+
+const (
+	// exitCodePending is the exit code of the status command when migrations are pending.
+	exitCodePending = 3
+	// exitCodeDrift is the exit code of the verify command when drift is found.
+	exitCodeDrift = 4
 )
 
 func main() {
-	if err := run(); err != nil {
+	err := run()
+	if errors.Is(err, errPendingMigrations) {
+		fmt.Println(err)
+		os.Exit(exitCodePending)
+	}
+	if errors.Is(err, errSchemaDrift) {
+		fmt.Println(err)
+		os.Exit(exitCodeDrift)
+	}
+	if err != nil {
 		fmt.Printf("error running migration: %v\n", err)
 		os.Exit(1)
 	}
@@ -19,19 +42,63 @@ func main() {
 func run() error {
 	ctx := context.Background()
 
-	cfg, err := NewConfig()
+	configLoader := newConfigLoader()
+	flags := configLoader.Flags()
+	output := flags.String("output", outputTable, "output format of the status command: table or json")
+	dir := flags.String("dir", postgresMigrationDir, "directory of the migration files for the create command")
+	dryRun := flags.Bool("dry-run", false, "print the pending migrations and their SQL without applying them, for the up and up-to commands")
+	schema := flags.Bool("schema", false, "also compare the tables against their expected definition, for the verify command")
+	seedCount := flags.Int("count", 10000, "number of users to generate, for the seed command")
+	seedValue := flags.Uint64("seed", 1, "seed of the generated users, the same seed generates the same users, for the seed command")
+	seedBatchSize := flags.Int("batch-size", 1000, "number of users copied per batch, for the seed command")
+	seedMode := flags.String("mode", postgres.SeedModeUpsert, "upsert to update existing users or truncate to delete all users first, for the seed command")
+
+	cfg, err := configLoader.Load(os.Args[1:])
+	if errors.Is(err, config.ErrExit) || errors.Is(err, config.ErrHelp) {
+		return nil
+	}
 	if err != nil {
-		return fmt.Errorf("new config: %w", err)
+		return fmt.Errorf("load config: %w", err)
 	}
 
-	if err := cfg.Validate(); err != nil {
-		return fmt.Errorf("validate config: %w", err)
+	cmd, err := parseCommand(flags.Args())
+	if err != nil {
+		return err
+	}
+	if *output != outputTable && *output != outputJSON {
+		return fmt.Errorf("invalid output %q, must be %s or %s", *output, outputTable, outputJSON)
+	}
+	if *dryRun && cmd.name != "up" && cmd.name != "up-to" {
+		return fmt.Errorf("--dry-run is not supported by the %s command", cmd.name)
 	}
 
-	logger, err := log.NewLogger(cfg.Log)
+	// Creating a migration file does not need a database connection.
+	if cmd.name == "create" {
+		if err := postgres.CreateMigration(*dir, cmd.args[0]); err != nil {
+			return fmt.Errorf("create migration: %w", err)
+		}
+		return nil
+	}
+
+	logger, cleanupLogger, err := log.NewLogger(cfg.Log)
 	if err != nil {
 		return fmt.Errorf("new logger: %w", err)
 	}
+	defer func() {
+		if err := cleanupLogger(); err != nil {
+			fmt.Printf("error closing log outputs: %v\n", err)
+		}
+	}()
+
+	cleanupTracer, err := telemetry.InitTracer(ctx, cfg.Otel, redact.New(cfg.Log.Redact))
+	if err != nil {
+		return fmt.Errorf("init tracer: %w", err)
+	}
+	defer func() {
+		if err := cleanupTracer(ctx); err != nil {
+			logger.ErrorContext(ctx, "error cleaning up tracer", slog.Any("error", err))
+		}
+	}()
 
 	pool, err := postgres.NewPgxPool(ctx, cfg.Postgres)
 	if err != nil {
@@ -39,12 +106,40 @@ func run() error {
 	}
 	defer pool.Close()
 
-	logger.InfoContext(ctx, "running migrations")
-	if err := postgres.Migrate(pool); err != nil {
-		return fmt.Errorf("migrate: %w", err)
+	if cmd.name == "seed" {
+		return postgres.SeedUsers(ctx, pool, logger, postgres.SeedOptions{
+			Count:     *seedCount,
+			Seed:      *seedValue,
+			BatchSize: *seedBatchSize,
+			Mode:      *seedMode,
+		})
 	}
 
-	logger.InfoContext(ctx, "migrations completed successfully")
+	migrator, err := postgres.NewMigrator(pool, cfg.Migration, logger)
+	if err != nil {
+		return fmt.Errorf("new migrator: %w", err)
+	}
+	defer func() {
+		if err := migrator.Close(); err != nil {
+			logger.ErrorContext(ctx, "error closing migrator", slog.Any("error", err))
+		}
+	}()
+
+	if *dryRun {
+		return printPlan(ctx, os.Stdout, migrator, cmd, *output)
+	}
+
+	err = runMigrator(ctx, logger, migrator, cmd, options{
+		output: *output,
+		schema: *schema,
+	})
+
+	// The job is too short-lived to be scraped, so the outcome is pushed whether it succeeded or not.
+	if cmd.migrates() {
+		if err := telemetry.PushMetrics(ctx, cfg.MetricsPush, prometheus.DefaultGatherer); err != nil {
+			logger.ErrorContext(ctx, "error pushing metrics", slog.Any("error", err))
+		}
+	}
 
-	return nil
+	return err
 }
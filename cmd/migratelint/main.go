@@ -0,0 +1,89 @@
+// Command migratelint checks the SQL migrations for operations that lock tables
+// for a long time, destroy data or cannot be rolled back.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/internal/migratelint"
+)
+
+// errViolations is returned when the migrations have violations.
+var errViolations = errors.New("migrations have violations")
+
+func main() {
+	err := run()
+	if errors.Is(err, errViolations) {
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Printf("error linting migrations: %v\n", err)
+		os.Exit(2)
+	}
+}
+
+func run() error {
+	f := flag.NewFlagSet("migratelint", flag.ContinueOnError)
+	dir := f.String("dir", "internal/postgres/migration", "directory of the migration files")
+	output := f.String("output", "text", "output format: text or json")
+	listRules := f.Bool("rules", false, "list the rules and exit")
+	f.Usage = func() {
+		fmt.Println("Usage: migratelint [flags]")
+		fmt.Println()
+		fmt.Println("Suppress a violation with a comment above the statement:")
+		fmt.Println("  -- lint:ignore <rule>[,<rule>...] <reason>")
+		fmt.Println("or for the whole file with -- lint:ignore-file <rule>[,<rule>...] <reason>.")
+		fmt.Println()
+		fmt.Println(f.FlagUsages())
+	}
+	if err := f.Parse(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	if *listRules {
+		rules := migratelint.Rules()
+		for _, name := range slices.Sorted(maps.Keys(rules)) {
+			fmt.Printf("%-28s %s\n", name, rules[name])
+		}
+		return nil
+	}
+
+	violations, err := migratelint.Lint(os.DirFS(*dir), ".")
+	if err != nil {
+		return err
+	}
+
+	switch *output {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if violations == nil {
+			violations = []migratelint.Violation{}
+		}
+		if err := enc.Encode(violations); err != nil {
+			return fmt.Errorf("encode violations: %w", err)
+		}
+	case "text":
+		for _, v := range violations {
+			fmt.Println(v)
+		}
+	default:
+		return fmt.Errorf("invalid output %q, must be text or json", *output)
+	}
+
+	if len(violations) > 0 {
+		return errViolations
+	}
+
+	return nil
+}
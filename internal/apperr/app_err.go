@@ -2,7 +2,91 @@ package apperr
 
 import "github.com/tuanvumaihuynh/victoria-o11y-lab/pkg/zerror"
 
+// Operation IDs of the API, referenced by the catalog.
+const (
+	OpCreateUser  = "create-user"
+	OpGetUserByID = "get-user-by-id"
+	OpUpdateUser  = "update-user"
+	OpDeleteUser  = "delete-user"
+	OpListUsers   = "list-users"
+	OpListErrors  = "list-errors"
+)
+
+// Operations returns the IDs of the operations of the API.
+func Operations() []string {
+	return []string{OpCreateUser, OpGetUserByID, OpUpdateUser, OpDeleteUser, OpListUsers, OpListErrors}
+}
+
 var (
-	InternalServerErr = zerror.NewInternalServerError("internal_server_error", "Internal server error")
-	ValidationError   = zerror.NewValidationFailed("validation_failed", "Validation failed")
+	InternalServerErr = register(
+		zerror.NewInternalServerError("internal_server_error", "Internal server error"),
+		"An unexpected error occurred. The request can be retried.",
+		AllOperations,
+	)
+	Timeout = register(
+		zerror.NewTimeout("timeout", "The request took too long to process"),
+		"The operation did not complete within its deadline. The request can be retried.",
+		AllOperations,
+	)
+	RequestTimeout = register(
+		zerror.NewRequestTimeout("request_timeout", "The request body took too long to read"),
+		"The request body was not received in time.",
+		OpCreateUser,
+		OpUpdateUser,
+	)
+	RequestBodyTooLarge = register(
+		zerror.NewContentTooLarge("request_body_too_large", "Request body is too large"),
+		"The request body, once decompressed, exceeds the size limit of the operation.",
+		OpCreateUser,
+		OpUpdateUser,
+	)
+	UnsupportedContentEncoding = register(
+		zerror.NewUnsupportedMediaType("unsupported_content_encoding", "Unsupported content encoding"),
+		"The request body is compressed with an encoding other than gzip, zstd or br.",
+		OpCreateUser,
+		OpUpdateUser,
+	)
+	InvalidCompressedBody = register(
+		zerror.NewBadRequest("invalid_compressed_body", "Invalid compressed request body"),
+		"The request body cannot be decompressed with its Content-Encoding.",
+		OpCreateUser,
+		OpUpdateUser,
+	)
+	ValidationError = register(
+		zerror.NewUnprocessableEntity("validation_failed", "Validation failed"),
+		"The request is invalid. The error details list the invalid fields.",
+		AllOperations,
+	)
+	PreconditionFailed = register(
+		zerror.NewPreconditionFailed("precondition_failed", "The resource has been modified"),
+		"The If-Match header does not match the current ETag of the resource. Get the resource again and retry with its ETag.",
+		OpUpdateUser,
+		OpDeleteUser,
+	)
+	PreconditionRequired = register(
+		zerror.NewPreconditionRequired("precondition_required", "If-Match header is required"),
+		"Updates and deletions must send the ETag of the resource in the If-Match header.",
+		OpUpdateUser,
+		OpDeleteUser,
+	)
+
+	// The user errors are reserved for the storage of the users: the handlers return
+	// stub users for now, so no operation declares them.
+	UserNotFound = register(
+		zerror.NewNotFound("user_not_found", "User not found"),
+		"Reserved. No user has the given ID.",
+	)
+	UserEmailTaken = register(
+		zerror.NewConflict("user_email_taken", "Email is already taken"),
+		"Reserved. Another user already has the given email.",
+	)
+
+	Unauthorized = register(
+		zerror.NewUnauthorized("unauthorized", "Missing or invalid admin token"),
+		"The admin endpoints require the admin token as a bearer token.",
+	)
+	InvalidBody = register(
+		zerror.NewBadRequest("invalid_body", "Invalid request body"),
+		"The body of a request to an admin endpoint cannot be decoded.",
+	)
 )
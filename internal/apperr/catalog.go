@@ -0,0 +1,69 @@
+package apperr
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/pkg/zerror"
+)
+
+// AllOperations declares an error that every operation can return.
+const AllOperations = "*"
+
+// Entry declares an error code of the catalog.
+type Entry struct {
+	Err         *zerror.ZError
+	Description string
+	// Operations are the IDs of the API operations that can return the error, or [AllOperations].
+	// Errors returned only by endpoints outside of the API, and reserved errors no
+	// operation returns yet, have none.
+	Operations []string
+}
+
+// catalog holds the registered errors by code.
+var catalog = map[string]Entry{}
+
+// register declares err in the catalog and returns it.
+// It panics if the code is already registered, so a code always means one error.
+func register(err *zerror.ZError, description string, operations ...string) *zerror.ZError {
+	if _, ok := catalog[err.Code()]; ok {
+		panic(fmt.Sprintf("apperr: error code %q registered twice", err.Code()))
+	}
+
+	catalog[err.Code()] = Entry{
+		Err:         err,
+		Description: description,
+		Operations:  operations,
+	}
+
+	return err
+}
+
+// Catalog returns the registered errors sorted by code.
+func Catalog() []Entry {
+	entries := make([]Entry, 0, len(catalog))
+	for _, code := range slices.Sorted(maps.Keys(catalog)) {
+		entries = append(entries, catalog[code])
+	}
+
+	return entries
+}
+
+// Registered reports whether code is declared in the catalog.
+func Registered(code string) bool {
+	_, ok := catalog[code]
+	return ok
+}
+
+// ForOperation returns the registered errors the operation can return, sorted by code.
+func ForOperation(operationID string) []Entry {
+	var entries []Entry
+	for _, entry := range Catalog() {
+		if slices.Contains(entry.Operations, AllOperations) || slices.Contains(entry.Operations, operationID) {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
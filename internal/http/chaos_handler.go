@@ -0,0 +1,123 @@
+package http
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/internal/apperr"
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/internal/http/middleware"
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/pkg/config"
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/pkg/zerror"
+)
+
+// ChaosAdminPath is the path to the chaos admin endpoint.
+const ChaosAdminPath = "/admin/chaos"
+
+// maxChaosRulesBytes bounds the size of the rules sent to the chaos admin endpoint.
+const maxChaosRulesBytes = 1 << 20 // 1 MB
+
+// chaosState is the body of the chaos admin endpoint.
+type chaosState struct {
+	Enabled bool                   `json:"enabled"`
+	Rules   []middleware.ChaosRule `json:"rules"`
+}
+
+// registerChaosAdmin registers the chaos admin endpoint:
+// GET returns the current rules, PUT replaces them and DELETE disables chaos and removes them.
+// The rules are replaced again when the config file is reloaded.
+func (s *Service) registerChaosAdmin(r chi.Router) {
+	r.Route(ChaosAdminPath, func(r chi.Router) {
+		r.Use(s.requireAdminToken)
+		r.Get("/", s.getChaos)
+		r.Put("/", s.putChaos)
+		r.Delete("/", s.deleteChaos)
+	})
+}
+
+func (s *Service) requireAdminToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.cfg.Chaos.AdminToken)) != 1 {
+			s.writeError(w, r, apperr.Unauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Service) getChaos(w http.ResponseWriter, r *http.Request) {
+	s.writeChaosState(w, r)
+}
+
+func (s *Service) putChaos(w http.ResponseWriter, r *http.Request) {
+	var state chaosState
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxChaosRulesBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&state); err != nil {
+		invalidBodyErr := zerror.WithMsg(*apperr.InvalidBody, "Invalid request body: "+err.Error())
+		s.writeError(w, r, &invalidBodyErr)
+		return
+	}
+
+	if err := s.chaos.SetRules(state.Rules); err != nil {
+		validationErr := zerror.WithDetails(*apperr.ValidationError, fieldErrorDetails("body", err)...)
+		s.writeError(w, r, &validationErr)
+		return
+	}
+	s.chaos.SetEnabled(state.Enabled)
+
+	s.logger.WarnContext(r.Context(), "chaos rules replaced",
+		slog.Bool("enabled", state.Enabled),
+		slog.Int("rules", len(state.Rules)),
+	)
+
+	s.writeChaosState(w, r)
+}
+
+func (s *Service) deleteChaos(w http.ResponseWriter, r *http.Request) {
+	s.chaos.SetEnabled(false)
+	// Removing all rules cannot fail validation.
+	_ = s.chaos.SetRules(nil)
+
+	s.logger.WarnContext(r.Context(), "chaos rules removed")
+
+	s.writeChaosState(w, r)
+}
+
+func (s *Service) writeChaosState(w http.ResponseWriter, r *http.Request) {
+	state := chaosState{
+		Enabled: s.chaos.Enabled(),
+		Rules:   s.chaos.Rules(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(state); err != nil {
+		s.logger.ErrorContext(r.Context(), "error writing chaos rules", slog.Any("error", err))
+	}
+}
+
+// fieldErrorDetails flattens the config field errors of err into details located under key.
+func fieldErrorDetails(key string, err error) []zerror.Detail {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var details []zerror.Detail
+		for _, err := range joined.Unwrap() {
+			details = append(details, fieldErrorDetails(key, err)...)
+		}
+		return details
+	}
+
+	if fe, ok := err.(*config.FieldError); ok {
+		if fe.Key != "" {
+			key += "." + fe.Key
+		}
+		return fieldErrorDetails(key, fe.Err)
+	}
+
+	return []zerror.Detail{{Field: key, Message: err.Error()}}
+}
@@ -0,0 +1,78 @@
+package http
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/internal/apperr"
+)
+
+// Outcomes of the conditional requests, counted by the http_conditional_requests_total metric.
+const (
+	// conditionalNotModified is a GET whose If-None-Match matched, answered with 304.
+	conditionalNotModified = "not_modified"
+	// conditionalModified is a GET whose If-None-Match did not match, answered with the full body.
+	conditionalModified = "modified"
+	// conditionalMatched is an update or deletion whose If-Match matched.
+	conditionalMatched              = "matched"
+	conditionalPreconditionFailed   = "precondition_failed"
+	conditionalPreconditionRequired = "precondition_required"
+)
+
+// etag returns the entity tag of a resource last updated at updatedAt.
+// The tag is weak: it identifies a version of the resource, not the bytes of a response,
+// which may be compressed or not. So a 304 carries the tag of its 200 whatever the encoding.
+func etag(updatedAt time.Time) string {
+	return `W/"` + strconv.FormatInt(updatedAt.UnixNano(), 36) + `"`
+}
+
+// notModified reports whether the If-None-Match header matches the current ETag of
+// the resource, in which case the response is 304 Not Modified without a body.
+func (s *Service) notModified(operationID, ifNoneMatch, current string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+
+	if !matchETag(ifNoneMatch, current) {
+		s.metrics.ConditionalRequests.WithLabelValues(operationID, conditionalModified).Inc()
+		return false
+	}
+	s.metrics.ConditionalRequests.WithLabelValues(operationID, conditionalNotModified).Inc()
+
+	return true
+}
+
+// checkIfMatch returns an error unless the If-Match header is present and matches the
+// current ETag of the resource, so the resource is not changed based on a stale copy.
+func (s *Service) checkIfMatch(operationID, ifMatch, current string) error {
+	if ifMatch == "" {
+		s.metrics.ConditionalRequests.WithLabelValues(operationID, conditionalPreconditionRequired).Inc()
+		return apperr.PreconditionRequired
+	}
+
+	if !matchETag(ifMatch, current) {
+		s.metrics.ConditionalRequests.WithLabelValues(operationID, conditionalPreconditionFailed).Inc()
+		return apperr.PreconditionFailed
+	}
+	s.metrics.ConditionalRequests.WithLabelValues(operationID, conditionalMatched).Inc()
+
+	return nil
+}
+
+// matchETag reports whether header, a list of entity tags or "*", matches etag.
+// The tags are compared weakly, If-Match included, since the ETags of the resources are weak.
+func matchETag(header, etag string) bool {
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+
+	etag = strings.TrimPrefix(etag, "W/")
+	for tag := range strings.SplitSeq(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
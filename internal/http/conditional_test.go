@@ -0,0 +1,123 @@
+package http
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/internal/apperr"
+)
+
+// userETag returns the current ETag of the stub user.
+func userETag(t *testing.T) string {
+	t.Helper()
+
+	rec := serve(t, http.MethodGet, "/api/v1/users/1", nil, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("get user status = %d, want 200", rec.Code)
+	}
+	tag := rec.Header().Get("ETag")
+	if tag == "" {
+		t.Fatal("get user response has no ETag")
+	}
+
+	return tag
+}
+
+func TestIfMatch(t *testing.T) {
+	current := userETag(t)
+	update := `{"name":"Jane Doe"}`
+
+	tests := []struct {
+		name      string
+		operation string
+		method    string
+		body      string
+		ifMatch   string
+		status    int
+		// code is the code of the error response, if any.
+		code string
+	}{
+		{name: "update without If-Match", operation: apperr.OpUpdateUser, method: http.MethodPatch, body: update, status: http.StatusPreconditionRequired, code: apperr.PreconditionRequired.Code()},
+		{name: "update with stale ETag", operation: apperr.OpUpdateUser, method: http.MethodPatch, body: update, ifMatch: `"stale"`, status: http.StatusPreconditionFailed, code: apperr.PreconditionFailed.Code()},
+		{name: "update with current ETag", operation: apperr.OpUpdateUser, method: http.MethodPatch, body: update, ifMatch: current, status: http.StatusOK},
+		{name: "update with one of several ETags", operation: apperr.OpUpdateUser, method: http.MethodPatch, body: update, ifMatch: `"stale", ` + current, status: http.StatusOK},
+		{name: "update with any ETag", operation: apperr.OpUpdateUser, method: http.MethodPatch, body: update, ifMatch: "*", status: http.StatusOK},
+		{name: "delete without If-Match", operation: apperr.OpDeleteUser, method: http.MethodDelete, status: http.StatusPreconditionRequired, code: apperr.PreconditionRequired.Code()},
+		{name: "delete with stale ETag", operation: apperr.OpDeleteUser, method: http.MethodDelete, ifMatch: `"stale"`, status: http.StatusPreconditionFailed, code: apperr.PreconditionFailed.Code()},
+		{name: "delete with current ETag", operation: apperr.OpDeleteUser, method: http.MethodDelete, ifMatch: current, status: http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.ifMatch != "" {
+				header.Set("If-Match", tt.ifMatch)
+			}
+			rec := serve(t, tt.method, "/api/v1/users/1", header, tt.body)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d, body %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.code == "" {
+				return
+			}
+			code := errorCode(t, rec)
+			if code != tt.code {
+				t.Errorf("code = %q, want %q", code, tt.code)
+			}
+			if !declared(tt.operation, code) {
+				t.Errorf("code %q is not declared for %s", code, tt.operation)
+			}
+		})
+	}
+}
+
+func TestUpdateUserETag(t *testing.T) {
+	current := userETag(t)
+
+	rec := serve(t, http.MethodPatch, "/api/v1/users/1", http.Header{"If-Match": {current}}, `{"name":"Jane Doe"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200, body %s", rec.Code, rec.Body)
+	}
+	// The update changes updated_at, so the user gets a new ETag.
+	if tag := rec.Header().Get("ETag"); tag == "" || tag == current {
+		t.Errorf("ETag = %q, want a new ETag", tag)
+	}
+}
+
+func TestIfNoneMatch(t *testing.T) {
+	for _, acceptEncoding := range []string{"", "gzip"} {
+		t.Run("Accept-Encoding "+acceptEncoding, func(t *testing.T) {
+			header := http.Header{}
+			if acceptEncoding != "" {
+				header.Set("Accept-Encoding", acceptEncoding)
+			}
+
+			rec := serve(t, http.MethodGet, "/api/v1/users/1", header, "")
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", rec.Code)
+			}
+			if got := rec.Header().Get("Content-Encoding"); got != acceptEncoding {
+				t.Fatalf("Content-Encoding = %q, want %q", got, acceptEncoding)
+			}
+			tag := rec.Header().Get("ETag")
+
+			// The 304 carries the ETag of the 200 it stands for.
+			header.Set("If-None-Match", tag)
+			rec = serve(t, http.MethodGet, "/api/v1/users/1", header, "")
+			if rec.Code != http.StatusNotModified {
+				t.Fatalf("status = %d, want 304", rec.Code)
+			}
+			if got := rec.Header().Get("ETag"); got != tag {
+				t.Errorf("304 ETag = %s, want the ETag of the 200 %s", got, tag)
+			}
+			if rec.Body.Len() != 0 {
+				t.Errorf("304 body = %q, want none", rec.Body)
+			}
+
+			header.Set("If-None-Match", `W/"stale"`)
+			if rec = serve(t, http.MethodGet, "/api/v1/users/1", header, ""); rec.Code != http.StatusOK {
+				t.Errorf("status with a stale ETag = %d, want 200", rec.Code)
+			}
+		})
+	}
+}
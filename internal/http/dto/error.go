@@ -1,12 +1,47 @@
 package dto
 
 type ErrorResponse struct {
-	Code         string        `json:"code"`
-	Message      string        `json:"message"`
-	ErrorDetails []ErrorDetail `json:"error_details,omitempty"`
+	Code          string        `json:"code"`
+	Message       string        `json:"message"`
+	ErrorDetails  []ErrorDetail `json:"error_details,omitempty"`
+	TraceID       string        `json:"trace_id,omitempty" example:"4bf92f3577b34da6a3ce929d0e0e4736"`
+	CorrelationID string        `json:"correlation_id,omitempty" example:"0b4ce5a4-4f1e-4a8e-9c57-2f7d5e0f3c1a"`
+	Chain         []string      `json:"chain,omitempty" doc:"Messages of the wrapped errors, outermost first. Only returned in debug mode."`
 }
 
 type ErrorDetail struct {
 	Field   string `json:"field"`
 	Message string `json:"message"`
 }
+
+// ProblemDetails is an error response in the RFC 9457 problem details format,
+// with the code, details, trace ID and correlation ID of the error as extension members.
+type ProblemDetails struct {
+	Type          string        `json:"type" example:"about:blank" doc:"A URI reference identifying the problem type"`
+	Title         string        `json:"title" example:"Unprocessable Entity" doc:"A short summary of the problem type"`
+	Status        int           `json:"status" example:"422" doc:"HTTP status code"`
+	Detail        string        `json:"detail" example:"Validation failed" doc:"An explanation specific to this occurrence of the problem"`
+	Instance      string        `json:"instance,omitempty" example:"/api/v1/users" doc:"A URI reference identifying this occurrence of the problem"`
+	Code          string        `json:"code" example:"validation_failed"`
+	Errors        []ErrorDetail `json:"errors,omitempty"`
+	TraceID       string        `json:"trace_id,omitempty" example:"4bf92f3577b34da6a3ce929d0e0e4736"`
+	CorrelationID string        `json:"correlation_id,omitempty" example:"0b4ce5a4-4f1e-4a8e-9c57-2f7d5e0f3c1a"`
+	Chain         []string      `json:"chain,omitempty" doc:"Messages of the wrapped errors, outermost first. Only returned in debug mode."`
+}
+
+type ListErrorsResponseBody struct {
+	Items []ErrorCatalogEntry `json:"items"`
+}
+
+type ListErrorsResponse struct {
+	Body ListErrorsResponseBody
+}
+
+// ErrorCatalogEntry describes an error code the API can return.
+type ErrorCatalogEntry struct {
+	Code        string   `json:"code" example:"user_not_found"`
+	Status      int      `json:"status" example:"404" doc:"HTTP status code"`
+	Message     string   `json:"message" example:"User not found"`
+	Description string   `json:"description" example:"No user has the given ID."`
+	Operations  []string `json:"operations" example:"[\"get-user-by-id\"]" doc:"IDs of the operations that can return the error, * for every operation"`
+}
@@ -4,9 +4,9 @@ import "time"
 
 type CreateUserRequestBody struct {
 	Name  string `json:"name" minLength:"1" example:"John Doe"`
-	Email string `json:"email" format:"email" example:"john.doe@example.com"`
+	Email string `json:"email" format:"email" example:"john.doe@example.com" redact:"email"`
 	//nolint:gosec
-	Password string `json:"password" minLength:"8" example:"password123"`
+	Password string `json:"password" minLength:"8" example:"password123" redact:"true"`
 }
 
 type CreateUserRequest struct {
@@ -16,7 +16,7 @@ type CreateUserRequest struct {
 type CreateUserResponseBody struct {
 	ID        string    `json:"id" example:"123e4567-e89b-12d3-a456-426614174000"`
 	Name      string    `json:"name" example:"John Doe"`
-	Email     string    `json:"email" example:"john.doe@example.com"`
+	Email     string    `json:"email" example:"john.doe@example.com" redact:"email"`
 	CreatedAt time.Time `json:"created_at" example:"2026-01-01T00:00:00Z"`
 	UpdatedAt time.Time `json:"updated_at" example:"2026-01-01T00:00:00Z"`
 }
@@ -26,11 +26,55 @@ type CreateUserResponse struct {
 }
 
 type GetUserByIDRequest struct {
-	ID string `path:"id" example:"123e4567-e89b-12d3-a456-426614174000"`
+	ID          string `path:"id" example:"123e4567-e89b-12d3-a456-426614174000"`
+	IfNoneMatch string `header:"If-None-Match" doc:"ETag of the cached user. The user is returned only if it has changed since." example:"W/\"1a2b3c\""`
 }
 
 type GetUserByIDResponseBody CreateUserResponseBody
 
 type GetUserByIDResponse struct {
-	Body GetUserByIDResponseBody
+	// Status is 304 when the user matches If-None-Match, and the body is not sent.
+	Status int
+	ETag   string `header:"ETag" doc:"ETag of the user, derived from updated_at"`
+	Body   GetUserByIDResponseBody
+}
+
+type UpdateUserRequestBody struct {
+	Name  *string `json:"name,omitempty" minLength:"1" example:"John Doe"`
+	Email *string `json:"email,omitempty" format:"email" example:"john.doe@example.com" redact:"email"`
+}
+
+type UpdateUserRequest struct {
+	ID      string `path:"id" example:"123e4567-e89b-12d3-a456-426614174000"`
+	IfMatch string `header:"If-Match" doc:"ETag of the user the update is based on. Required." example:"W/\"1a2b3c\""`
+	Body    UpdateUserRequestBody
+}
+
+type UpdateUserResponseBody CreateUserResponseBody
+
+type UpdateUserResponse struct {
+	ETag string `header:"ETag" doc:"ETag of the updated user, derived from updated_at"`
+	Body UpdateUserResponseBody
+}
+
+type DeleteUserRequest struct {
+	ID      string `path:"id" example:"123e4567-e89b-12d3-a456-426614174000"`
+	IfMatch string `header:"If-Match" doc:"ETag of the user to delete. Required." example:"W/\"1a2b3c\""`
+}
+
+type DeleteUserResponse struct{}
+
+type ListUsersRequest struct {
+	Limit  int `query:"limit" minimum:"1" maximum:"100" default:"20" example:"20"`
+	Offset int `query:"offset" minimum:"0" default:"0" example:"0"`
+}
+
+type ListUsersResponseBody struct {
+	Items  []CreateUserResponseBody `json:"items"`
+	Limit  int                      `json:"limit" example:"20"`
+	Offset int                      `json:"offset" example:"0"`
+}
+
+type ListUsersResponse struct {
+	Body ListUsersResponseBody
 }
@@ -1,55 +1,129 @@
 package http
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/danielgtaylor/huma/v2"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/tuanvumaihuynh/victoria-o11y-lab/internal/apperr"
 	"github.com/tuanvumaihuynh/victoria-o11y-lab/internal/http/dto"
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/pkg/config"
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/pkg/correlationid"
 	"github.com/tuanvumaihuynh/victoria-o11y-lab/pkg/zerror"
 )
 
-var internalServerErrResponse = &humaErrorResponse{
-	ErrorResponse: dto.ErrorResponse{
-		Code:    apperr.InternalServerErr.Code(),
-		Message: apperr.InternalServerErr.Msg(),
-	},
-	statusCode: http.StatusInternalServerError,
+// ErrorFormat is the format of the error responses.
+type ErrorFormat string
+
+const (
+	// ErrorFormatJSON is the {code, message, error_details} envelope.
+	ErrorFormatJSON ErrorFormat = "json"
+	// ErrorFormatProblem is the RFC 9457 problem details format, served as application/problem+json.
+	ErrorFormatProblem ErrorFormat = "problem"
+)
+
+const problemContentType = "application/problem+json"
+
+// ErrorsConfig configures the format of the error responses.
+type ErrorsConfig struct {
+	// Format is the format of the error responses: json or problem.
+	Format ErrorFormat `yaml:"format" enum:"json,problem"`
+	// Negotiate lets clients choose the format with the Accept header: application/problem+json
+	// selects the problem format and application/json the json format.
+	Negotiate bool `yaml:"negotiate"`
+	// TypeBaseURL is joined with the error code to build the type of the problems,
+	// e.g. https://errors.example.com/ gives https://errors.example.com/not_found.
+	// The type is about:blank when empty.
+	TypeBaseURL string `yaml:"type_base_url"`
+	// Debug adds the chain of the wrapped errors to the responses. It exposes internal
+	// details, so only enable it in development.
+	Debug bool `yaml:"debug"`
+}
+
+func (c *ErrorsConfig) Validate() error {
+	switch c.Format {
+	case ErrorFormatJSON, ErrorFormatProblem:
+		return nil
+	default:
+		return config.Fieldf("format", "must be one of %s or %s", ErrorFormatJSON, ErrorFormatProblem)
+	}
 }
 
-func newHumaError(logger *slog.Logger) func(status int, message string, errs ...error) huma.StatusError {
+// format returns the format of the error responses to a request accepting accept.
+func (c *ErrorsConfig) format(accept string) ErrorFormat {
+	if !c.Negotiate || accept == "" {
+		return c.Format
+	}
+
+	switch {
+	case strings.Contains(accept, problemContentType):
+		return ErrorFormatProblem
+	case strings.Contains(accept, "application/json"):
+		return ErrorFormatJSON
+	default:
+		return c.Format
+	}
+}
+
+func internalServerErrResponse() *humaErrorResponse {
+	return &humaErrorResponse{
+		ErrorResponse: dto.ErrorResponse{
+			Code:    apperr.InternalServerErr.Code(),
+			Message: apperr.InternalServerErr.Msg(),
+		},
+		statusCode: http.StatusInternalServerError,
+	}
+}
+
+func newHumaError(logger *slog.Logger, errCfg ErrorsConfig) func(status int, message string, errs ...error) huma.StatusError {
 	return func(status int, message string, errs ...error) huma.StatusError {
 		if len(errs) == 0 {
-			return internalServerErrResponse
+			if zErr, ok := statusToZError(status); ok {
+				return errorsToErrorResponse(zErr).withFormat(context.Background(), errCfg, errCfg.Format, "")
+			}
+			return internalServerErrResponse().withFormat(context.Background(), errCfg, errCfg.Format, "")
 		}
 
-		// Huma returns multiple errors only for validation failures.
+		// Huma returns multiple errors only for validation failures, and a single
+		// validation failure as one *huma.ErrorDetail.
 		// If huma behavior changes, revisit this logic.
 		// https://github.com/danielgtaylor/huma/blob/887f7d43222686b060805a934ab33a417b44e2fc/huma.go#L1071-L1085
-		if len(errs) > 1 {
-			return validationErrorsToErrorResponse(errs)
+		if len(errs) > 1 || isValidationError(errs[0]) {
+			return validationErrorsToErrorResponse(errs).withFormat(context.Background(), errCfg, errCfg.Format, "")
 		}
 
 		// Only handle the first error
 		err := errs[0]
-		errResp := errorsToErrorResponse(err)
+		errResp := errorsToErrorResponse(err).withChain(errCfg, err)
 		if errResp.GetStatus() >= 500 {
 			logger.Error("handler error", slog.Any("error", err))
 		}
+		warnUnregistered(context.Background(), logger, err)
 
-		return errResp
+		return errResp.withFormat(context.Background(), errCfg, errCfg.Format, "")
 	}
 }
 
-func newHumaErrorWithContext(logger *slog.Logger) func(hctx huma.Context, status int, message string, errs ...error) huma.StatusError {
+func newHumaErrorWithContext(logger *slog.Logger, errCfg ErrorsConfig) func(hctx huma.Context, status int, message string, errs ...error) huma.StatusError {
 	return func(hctx huma.Context, status int, message string, errs ...error) huma.StatusError {
 		ctx := hctx.Context()
+		format := errCfg.format(hctx.Header("Accept"))
+		instance := hctx.URL().Path
 
 		if len(errs) == 0 {
+			if zErr, ok := statusToZError(status); ok {
+				return errorsToErrorResponse(zErr).withFormat(ctx, errCfg, format, instance)
+			}
 			if status != 0 {
 				logger.ErrorContext(
 					ctx,
@@ -58,27 +132,124 @@ func newHumaErrorWithContext(logger *slog.Logger) func(hctx huma.Context, status
 					slog.String("message", message),
 				)
 			}
-			return internalServerErrResponse
+			return internalServerErrResponse().withFormat(ctx, errCfg, format, instance)
 		}
 
-		// Huma returns multiple errors only for validation failures.
+		// Huma returns multiple errors only for validation failures, and a single
+		// validation failure as one *huma.ErrorDetail.
 		// If huma behavior changes, revisit this logic.
 		// https://github.com/danielgtaylor/huma/blob/887f7d43222686b060805a934ab33a417b44e2fc/huma.go#L1071-L1085
-		if len(errs) > 1 {
-			return validationErrorsToErrorResponse(errs)
+		if len(errs) > 1 || isValidationError(errs[0]) {
+			return validationErrorsToErrorResponse(errs).withFormat(ctx, errCfg, format, instance)
 		}
 
 		// Only handle the first error
 		err := errs[0]
-		errResp := errorsToErrorResponse(err)
+		errResp := errorsToErrorResponse(err).withChain(errCfg, err)
 		if errResp.GetStatus() >= 500 {
 			logger.ErrorContext(ctx, "handler error", slog.Any("error", err))
 		}
+		warnUnregistered(ctx, logger, err)
 
-		return errResp
+		return errResp.withFormat(ctx, errCfg, format, instance)
 	}
 }
 
+// writeError logs err if it is a server error and writes it as an error response,
+// the same way huma writes the errors of the handlers.
+// It is used by the handlers and middlewares that are not registered with huma.
+func (s *Service) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	errResp := errorsToErrorResponse(err)
+	if errResp.GetStatus() >= 500 {
+		s.logger.ErrorContext(r.Context(), "handler error", slog.Any("error", err))
+	}
+
+	s.renderError(w, r, err)
+}
+
+// renderError writes err as an error response in the format negotiated for r, without logging it.
+func (s *Service) renderError(w http.ResponseWriter, r *http.Request, err error) {
+	ctx := r.Context()
+
+	errResp := errorsToErrorResponse(err).
+		withChain(s.cfg.Errors, err).
+		withFormat(ctx, s.cfg.Errors, s.cfg.Errors.format(r.Header.Get("Accept")), r.URL.Path)
+
+	w.Header().Set("Content-Type", errResp.ContentType("application/json"))
+	w.WriteHeader(errResp.GetStatus())
+	if err := json.NewEncoder(w).Encode(errResp); err != nil {
+		s.logger.ErrorContext(ctx, "error writing error response", slog.Any("error", err))
+	}
+}
+
+// warnUnregistered logs err if it is a ZError whose code is missing from the error catalog,
+// so clients cannot discover it.
+func warnUnregistered(ctx context.Context, logger *slog.Logger, err error) {
+	zErr, ok := errors.AsType[*zerror.ZError](err)
+	if !ok || apperr.Registered(zErr.Code()) {
+		return
+	}
+
+	logger.WarnContext(ctx, "handler returned an error code missing from the error catalog",
+		slog.String("code", zErr.Code()),
+	)
+}
+
+// operationErrorStatuses returns the HTTP statuses of the errors the operation can return,
+// according to the error catalog.
+func operationErrorStatuses(operationID string) []int {
+	var statuses []int
+	for _, entry := range apperr.ForOperation(operationID) {
+		status := zErrorStatusToHTTPStatus(entry.Err.Status())
+		if !slices.Contains(statuses, status) {
+			statuses = append(statuses, status)
+		}
+	}
+	slices.Sort(statuses)
+
+	return statuses
+}
+
+// documentErrors documents the error responses of op with a schema per format
+// the clients can receive, and the codes of the error catalog returned with each status.
+func (s *Service) documentErrors(oapi *huma.OpenAPI, op *huma.Operation) {
+	registry := oapi.Components.Schemas
+	content := make(map[string]*huma.MediaType, 2)
+	if s.cfg.Errors.Format == ErrorFormatJSON || s.cfg.Errors.Negotiate {
+		content["application/json"] = &huma.MediaType{
+			Schema: registry.Schema(reflect.TypeFor[dto.ErrorResponse](), true, "ErrorResponse"),
+		}
+	}
+	if s.cfg.Errors.Format == ErrorFormatProblem || s.cfg.Errors.Negotiate {
+		content[problemContentType] = &huma.MediaType{
+			Schema: registry.Schema(reflect.TypeFor[dto.ProblemDetails](), true, "ProblemDetails"),
+		}
+	}
+
+	codes := make(map[int][]string)
+	for _, entry := range apperr.ForOperation(op.OperationID) {
+		status := zErrorStatusToHTTPStatus(entry.Err.Status())
+		codes[status] = append(codes[status], entry.Err.Code())
+	}
+
+	for code, resp := range op.Responses {
+		status, err := strconv.Atoi(code)
+		if code != "default" && (err != nil || status < 400) {
+			continue
+		}
+
+		resp.Content = content
+		if len(codes[status]) > 0 {
+			resp.Description = fmt.Sprintf("%s. Error codes: %s.", http.StatusText(status), strings.Join(codes[status], ", "))
+		}
+	}
+}
+
+func isValidationError(err error) bool {
+	_, ok := errors.AsType[*huma.ErrorDetail](err)
+	return ok
+}
+
 func validationErrorsToErrorResponse(errs []error) *humaErrorResponse {
 	convertFunc := func(err error) *dto.ErrorDetail {
 		humaErr, ok := errors.AsType[*huma.ErrorDetail](err)
@@ -110,13 +281,35 @@ func validationErrorsToErrorResponse(errs []error) *humaErrorResponse {
 	}
 }
 
+// statusToZError returns the error of the statuses huma responds with on its own,
+// without an error, e.g. when the request body is too large.
+func statusToZError(status int) (*zerror.ZError, bool) {
+	switch status {
+	case http.StatusRequestEntityTooLarge:
+		return apperr.RequestBodyTooLarge, true
+	case http.StatusRequestTimeout:
+		return apperr.RequestTimeout, true
+	default:
+		return nil, false
+	}
+}
+
 func errorsToErrorResponse(err error) *humaErrorResponse {
+	// Handlers running past the deadline of their operation fail with the context error.
+	if errors.Is(err, context.DeadlineExceeded) {
+		if _, ok := errors.AsType[*zerror.ZError](err); !ok {
+			timeoutErr := zerror.WithParent(*apperr.Timeout, err)
+			err = &timeoutErr
+		}
+	}
+
 	zErr, ok := errors.AsType[*zerror.ZError](err)
 	if ok {
 		return &humaErrorResponse{
 			ErrorResponse: dto.ErrorResponse{
-				Code:    zErr.Code(),
-				Message: zErr.Msg(),
+				Code:         zErr.Code(),
+				Message:      zErr.Msg(),
+				ErrorDetails: zErrorDetailsToErrorDetails(zErr.Details()),
 			},
 			statusCode: zErrorStatusToHTTPStatus(zErr.Status()),
 		}
@@ -132,11 +325,117 @@ func errorsToErrorResponse(err error) *humaErrorResponse {
 	}
 }
 
-var _ huma.StatusError = (*humaErrorResponse)(nil)
+// zErrorDetailsToErrorDetails converts the details of a domain error to the
+// error details huma validation failures are returned with.
+func zErrorDetailsToErrorDetails(details []zerror.Detail) []dto.ErrorDetail {
+	if len(details) == 0 {
+		return nil
+	}
+
+	errDetails := make([]dto.ErrorDetail, len(details))
+	for i, d := range details {
+		errDetails[i] = dto.ErrorDetail{
+			Field:   d.Field,
+			Message: d.Message,
+		}
+	}
+
+	return errDetails
+}
+
+var (
+	_ huma.StatusError       = (*humaErrorResponse)(nil)
+	_ huma.ContentTypeFilter = (*humaErrorResponse)(nil)
+)
 
 type humaErrorResponse struct {
 	dto.ErrorResponse
 	statusCode int `json:"-"`
+	// problem is the response in the problem details format, or nil to write ErrorResponse.
+	problem *dto.ProblemDetails
+}
+
+// withFormat returns the response in format, with the trace and correlation IDs of ctx.
+// The problem format is completed with the instance.
+func (e *humaErrorResponse) withFormat(ctx context.Context, errCfg ErrorsConfig, format ErrorFormat, instance string) *humaErrorResponse {
+	if spanCtx := trace.SpanContextFromContext(ctx); spanCtx.HasTraceID() {
+		e.TraceID = spanCtx.TraceID().String()
+	}
+	if correlationID, ok := correlationid.FromContext(ctx); ok {
+		e.CorrelationID = correlationID
+	}
+
+	if format != ErrorFormatProblem {
+		return e
+	}
+
+	problemType := "about:blank"
+	if errCfg.TypeBaseURL != "" {
+		problemType = strings.TrimSuffix(errCfg.TypeBaseURL, "/") + "/" + e.Code
+	}
+
+	e.problem = &dto.ProblemDetails{
+		Type:          problemType,
+		Title:         http.StatusText(e.statusCode),
+		Status:        e.statusCode,
+		Detail:        e.Message,
+		Instance:      instance,
+		Code:          e.Code,
+		Errors:        e.ErrorDetails,
+		TraceID:       e.TraceID,
+		CorrelationID: e.CorrelationID,
+		Chain:         e.Chain,
+	}
+
+	return e
+}
+
+// withChain adds the chain of err to the response when debug is enabled.
+func (e *humaErrorResponse) withChain(errCfg ErrorsConfig, err error) *humaErrorResponse {
+	if errCfg.Debug {
+		e.Chain = errorChain(err)
+	}
+	return e
+}
+
+// errorChain returns the message of err and of every error it wraps, outermost first.
+// The message of each error is stripped of the message of the error it wraps,
+// and ZErrors are described by their code and message.
+func errorChain(err error) []string {
+	var chain []string
+	for err != nil {
+		next := errors.Unwrap(err)
+
+		var msg string
+		if zErr, ok := err.(*zerror.ZError); ok {
+			msg = zErr.Code() + ": " + zErr.Msg()
+		} else {
+			msg = err.Error()
+			if next != nil {
+				msg = strings.TrimSuffix(msg, ": "+next.Error())
+			}
+		}
+		chain = append(chain, msg)
+
+		err = next
+	}
+
+	return chain
+}
+
+func (e *humaErrorResponse) MarshalJSON() ([]byte, error) {
+	if e.problem != nil {
+		return json.Marshal(e.problem)
+	}
+	return json.Marshal(e.ErrorResponse)
+}
+
+// ContentType implements huma.ContentTypeFilter, serving problems as application/problem+json.
+func (e *humaErrorResponse) ContentType(ct string) string {
+	if e.problem != nil {
+		return problemContentType
+	}
+	return ct
 }
 
 func (e *humaErrorResponse) Error() string {
@@ -175,6 +474,16 @@ func zErrorStatusToHTTPStatus(status zerror.Status) int {
 		return http.StatusBadGateway
 	case zerror.StatusServiceUnavailable:
 		return http.StatusServiceUnavailable
+	case zerror.StatusContentTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case zerror.StatusRequestTimeout:
+		return http.StatusRequestTimeout
+	case zerror.StatusUnsupportedMediaType:
+		return http.StatusUnsupportedMediaType
+	case zerror.StatusPreconditionFailed:
+		return http.StatusPreconditionFailed
+	case zerror.StatusPreconditionRequired:
+		return http.StatusPreconditionRequired
 	default:
 		return http.StatusInternalServerError
 	}
@@ -0,0 +1,39 @@
+package http
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/internal/apperr"
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/internal/http/dto"
+)
+
+func ListErrorsDocs() huma.Operation {
+	return huma.Operation{
+		OperationID:   apperr.OpListErrors,
+		Summary:       "List error codes",
+		Description:   "List the error codes the API can return, with their status and the operations returning them",
+		DefaultStatus: http.StatusOK,
+		Tags:          []string{"errors"},
+	}
+}
+
+func (s *Service) ListErrors(ctx context.Context, req *struct{}) (*dto.ListErrorsResponse, error) {
+	catalog := apperr.Catalog()
+	items := make([]dto.ErrorCatalogEntry, len(catalog))
+	for i, entry := range catalog {
+		items[i] = dto.ErrorCatalogEntry{
+			Code:        entry.Err.Code(),
+			Status:      zErrorStatusToHTTPStatus(entry.Err.Status()),
+			Message:     entry.Err.Msg(),
+			Description: entry.Description,
+			Operations:  append([]string{}, entry.Operations...),
+		}
+	}
+
+	return &dto.ListErrorsResponse{
+		Body: dto.ListErrorsResponseBody{Items: items},
+	}, nil
+}
@@ -0,0 +1,246 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/go-chi/chi/v5"
+
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/internal/apperr"
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/internal/http/middleware"
+)
+
+const testAdminToken = "admin-token"
+
+// testService returns a service configured like cmd/api.
+// The tests share it, since the metrics of a service are registered globally.
+var testService = sync.OnceValue(func() *Service {
+	return New(Config{
+		Errors: ErrorsConfig{Format: ErrorFormatJSON},
+		Limits: LimitsConfig{Timeout: 5 * time.Second, MaxBodyBytes: 1024},
+		Compression: middleware.CompressConfig{
+			Enabled:   true,
+			Encodings: []string{middleware.EncodingGzip},
+			// Low enough for the user responses to be compressed.
+			MinSize:      64,
+			ContentTypes: []string{"application/json"},
+		},
+		Decompression: middleware.DecompressConfig{Enabled: true, MaxBytes: 1 << 20},
+		Chaos:         ChaosConfig{AdminToken: testAdminToken},
+	}, slog.New(slog.DiscardHandler))
+})
+
+// testHandler returns the handler of the test service.
+var testHandler = sync.OnceValues(func() (http.Handler, error) {
+	return testService().handler()
+})
+
+// serve sends a request with a JSON body to the test handler.
+func serve(t *testing.T, method, path string, header http.Header, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	handler, err := testHandler()
+	if err != nil {
+		t.Fatalf("handler() error = %v", err)
+	}
+
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	return rec
+}
+
+// errorCode decodes the code of an error response.
+func errorCode(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+
+	var resp struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode error response: %v", err)
+	}
+
+	return resp.Code
+}
+
+// TestErrorCodesRegistered drives the error paths of the operations and of the endpoints
+// outside of the API, and checks the codes they respond with are in the error catalog,
+// declared for the operation when there is one.
+func TestErrorCodesRegistered(t *testing.T) {
+	validUser := `{"name":"John Doe","email":"john.doe@example.com","password":"password123"}`
+	tests := []struct {
+		name string
+		// operation is the ID of the operation, or empty for the endpoints outside of the API.
+		operation string
+		method    string
+		path      string
+		header    http.Header
+		body      string
+		status    int
+		code      string
+	}{
+		{
+			name:      "invalid field",
+			operation: apperr.OpCreateUser,
+			method:    http.MethodPost,
+			path:      "/api/v1/users",
+			body:      `{"name":"John Doe","email":"not-an-email","password":"password123"}`,
+			status:    http.StatusUnprocessableEntity,
+			code:      apperr.ValidationError.Code(),
+		},
+		{
+			name:      "invalid fields",
+			operation: apperr.OpCreateUser,
+			method:    http.MethodPost,
+			path:      "/api/v1/users",
+			body:      `{"name":"","email":"not-an-email","password":"short"}`,
+			status:    http.StatusUnprocessableEntity,
+			code:      apperr.ValidationError.Code(),
+		},
+		{
+			name:      "body too large",
+			operation: apperr.OpCreateUser,
+			method:    http.MethodPost,
+			path:      "/api/v1/users",
+			body:      `{"name":"` + strings.Repeat("a", 2048) + `","email":"john.doe@example.com","password":"password123"}`,
+			status:    http.StatusRequestEntityTooLarge,
+			code:      apperr.RequestBodyTooLarge.Code(),
+		},
+		{
+			name:      "unsupported content encoding",
+			operation: apperr.OpCreateUser,
+			method:    http.MethodPost,
+			path:      "/api/v1/users",
+			header:    http.Header{"Content-Encoding": {"deflate"}},
+			body:      validUser,
+			status:    http.StatusUnsupportedMediaType,
+			code:      apperr.UnsupportedContentEncoding.Code(),
+		},
+		{
+			name:      "invalid compressed body",
+			operation: apperr.OpCreateUser,
+			method:    http.MethodPost,
+			path:      "/api/v1/users",
+			header:    http.Header{"Content-Encoding": {"gzip"}},
+			body:      validUser,
+			status:    http.StatusBadRequest,
+			code:      apperr.InvalidCompressedBody.Code(),
+		},
+		{
+			name:      "invalid query",
+			operation: apperr.OpListUsers,
+			method:    http.MethodGet,
+			path:      "/api/v1/users?limit=0",
+			status:    http.StatusUnprocessableEntity,
+			code:      apperr.ValidationError.Code(),
+		},
+		{
+			name:   "missing admin token",
+			method: http.MethodGet,
+			path:   ChaosAdminPath,
+			status: http.StatusUnauthorized,
+			code:   apperr.Unauthorized.Code(),
+		},
+		{
+			name:   "invalid admin body",
+			method: http.MethodPut,
+			path:   ChaosAdminPath,
+			header: http.Header{"Authorization": {"Bearer " + testAdminToken}},
+			body:   `{"rules":`,
+			status: http.StatusBadRequest,
+			code:   apperr.InvalidBody.Code(),
+		},
+		{
+			name:   "invalid chaos rules",
+			method: http.MethodPut,
+			path:   ChaosAdminPath,
+			header: http.Header{"Authorization": {"Bearer " + testAdminToken}},
+			body:   `{"enabled":true,"rules":[{"name":"Invalid Name"}]}`,
+			status: http.StatusUnprocessableEntity,
+			code:   apperr.ValidationError.Code(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(t, tt.method, tt.path, tt.header, tt.body)
+
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d, body %s", rec.Code, tt.status, rec.Body)
+			}
+			code := errorCode(t, rec)
+			if code != tt.code {
+				t.Errorf("code = %q, want %q", code, tt.code)
+			}
+
+			if !apperr.Registered(code) {
+				t.Fatalf("code %q is missing from the error catalog", code)
+			}
+			if tt.operation != "" && !declared(tt.operation, code) {
+				t.Errorf("code %q is not declared for %s", code, tt.operation)
+			}
+		})
+	}
+}
+
+// TestErrorCatalogOperations checks the operations listed by apperr and those the catalog
+// declares errors for exist, and document the statuses of the errors declared for them.
+func TestErrorCatalogOperations(t *testing.T) {
+	s := &Service{cfg: Config{Errors: ErrorsConfig{Format: ErrorFormatJSON}}, logger: slog.New(slog.DiscardHandler)}
+	api := s.newHumaAPI(chi.NewRouter())
+	s.RegisterRoutes(api)
+
+	operations := make(map[string]*huma.Operation)
+	for _, item := range api.OpenAPI().Paths {
+		for _, op := range []*huma.Operation{item.Get, item.Post, item.Put, item.Patch, item.Delete} {
+			if op != nil {
+				operations[op.OperationID] = op
+			}
+		}
+	}
+
+	for _, id := range apperr.Operations() {
+		if _, ok := operations[id]; !ok {
+			t.Errorf("%s is not an operation of the API", id)
+		}
+	}
+	if len(operations) != len(apperr.Operations()) {
+		t.Errorf("the API has %d operations, apperr.Operations() lists %d", len(operations), len(apperr.Operations()))
+	}
+	for _, entry := range apperr.Catalog() {
+		for _, id := range entry.Operations {
+			if _, ok := operations[id]; id != apperr.AllOperations && !ok {
+				t.Errorf("%s is declared for %s, which is not an operation of the API", entry.Err.Code(), id)
+			}
+		}
+	}
+	for id, op := range operations {
+		for _, entry := range apperr.ForOperation(id) {
+			status := strconv.Itoa(zErrorStatusToHTTPStatus(entry.Err.Status()))
+			if _, ok := op.Responses[status]; !ok {
+				t.Errorf("%s does not document the %s status of %s", id, status, entry.Err.Code())
+			}
+		}
+	}
+}
+
+func declared(operationID, code string) bool {
+	return slices.ContainsFunc(apperr.ForOperation(operationID), func(entry apperr.Entry) bool {
+		return entry.Err.Code() == code
+	})
+}
@@ -0,0 +1,133 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/internal/apperr"
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/pkg/config"
+)
+
+// ServerConfig configures the timeouts of the HTTP server.
+type ServerConfig struct {
+	// ReadTimeout is the maximum duration for reading an entire request, including the body.
+	ReadTimeout time.Duration `yaml:"read_timeout"`
+	// ReadHeaderTimeout is the maximum duration for reading the request headers.
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	// WriteTimeout is the maximum duration before timing out writes of the response.
+	// It must exceed the timeouts of the operations, or their timeout responses are never written.
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+	// IdleTimeout is the maximum duration to wait for the next request on a keep-alive connection.
+	IdleTimeout time.Duration `yaml:"idle_timeout"`
+	// ShutdownTimeout bounds the time spent waiting for the requests in progress on shutdown.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+func (c *ServerConfig) Validate() error {
+	var errs []error
+	if c.ReadTimeout < 0 {
+		errs = append(errs, config.Fieldf("read_timeout", "must not be negative"))
+	}
+	if c.ReadHeaderTimeout < 0 {
+		errs = append(errs, config.Fieldf("read_header_timeout", "must not be negative"))
+	}
+	if c.WriteTimeout < 0 {
+		errs = append(errs, config.Fieldf("write_timeout", "must not be negative"))
+	}
+	if c.IdleTimeout < 0 {
+		errs = append(errs, config.Fieldf("idle_timeout", "must not be negative"))
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, config.Fieldf("shutdown_timeout", "must be greater than 0"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// LimitsConfig configures the deadline and the maximum request body size of the API operations.
+type LimitsConfig struct {
+	// Timeout is the deadline of the handlers. It is propagated through the context,
+	// e.g. to the database queries, and responded to with 504. 0 disables it.
+	Timeout time.Duration `yaml:"timeout"`
+	// MaxBodyBytes is the maximum size of the request bodies. Larger bodies are responded to with 413.
+	MaxBodyBytes int64 `yaml:"max_body_bytes"`
+	// Operations overrides the limits of operations by their ID, e.g. create-user.
+	Operations map[string]OperationLimits `yaml:"operations"`
+}
+
+// OperationLimits overrides the limits of an operation. Zero values keep the default limits.
+type OperationLimits struct {
+	Timeout      time.Duration `yaml:"timeout"`
+	MaxBodyBytes int64         `yaml:"max_body_bytes"`
+}
+
+func (c *LimitsConfig) Validate() error {
+	var errs []error
+	if c.Timeout < 0 {
+		errs = append(errs, config.Fieldf("timeout", "must not be negative"))
+	}
+	if c.MaxBodyBytes <= 0 {
+		errs = append(errs, config.Fieldf("max_body_bytes", "must be greater than 0"))
+	}
+	for id, limits := range c.Operations {
+		if !slices.Contains(apperr.Operations(), id) {
+			errs = append(errs, config.Fieldf("operations."+id, "is not an operation, must be one of %s", strings.Join(apperr.Operations(), ", ")))
+		}
+		if limits.Timeout < 0 {
+			errs = append(errs, config.Fieldf("operations."+id+".timeout", "must not be negative"))
+		}
+		if limits.MaxBodyBytes < 0 {
+			errs = append(errs, config.Fieldf("operations."+id+".max_body_bytes", "must not be negative"))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// operation returns the limits of the operation, with the defaults applied.
+func (c *LimitsConfig) operation(operationID string) OperationLimits {
+	limits := OperationLimits{Timeout: c.Timeout, MaxBodyBytes: c.MaxBodyBytes}
+	if override, ok := c.Operations[operationID]; ok {
+		if override.Timeout > 0 {
+			limits.Timeout = override.Timeout
+		}
+		if override.MaxBodyBytes > 0 {
+			limits.MaxBodyBytes = override.MaxBodyBytes
+		}
+	}
+
+	return limits
+}
+
+// limitDuration is a huma middleware running the handler of the operation with its deadline.
+// Operations failing with the timeout error are marked on the span and counted; a handler
+// completing successfully as the deadline passes is not a timeout.
+func (s *Service) limitDuration(hctx huma.Context, next func(huma.Context)) {
+	op := hctx.Operation()
+	timeout := s.cfg.Limits.operation(op.OperationID).Timeout
+	if timeout <= 0 {
+		next(hctx)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(hctx.Context(), timeout)
+	defer cancel()
+
+	next(huma.WithContext(hctx, ctx))
+
+	if hctx.Status() == http.StatusGatewayTimeout && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		trace.SpanFromContext(ctx).AddEvent("operation timeout", trace.WithAttributes(
+			attribute.String("operation", op.OperationID),
+			attribute.String("timeout", timeout.String()),
+		))
+		s.metrics.OperationTimeoutsTotal.WithLabelValues(op.OperationID).Inc()
+	}
+}
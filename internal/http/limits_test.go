@@ -0,0 +1,136 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/internal/apperr"
+)
+
+func TestLimitsConfigValidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		operations map[string]OperationLimits
+		wantErr    string
+	}{
+		{name: "no operations"},
+		{
+			name:       "known operation",
+			operations: map[string]OperationLimits{apperr.OpCreateUser: {Timeout: time.Second}},
+		},
+		{
+			name:       "unknown operation",
+			operations: map[string]OperationLimits{"create-users": {Timeout: time.Second}},
+			wantErr:    "operations.create-users: is not an operation",
+		},
+		{
+			name:       "negative timeout",
+			operations: map[string]OperationLimits{apperr.OpListUsers: {Timeout: -time.Second}},
+			wantErr:    "operations.list-users.timeout: must not be negative",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := LimitsConfig{Timeout: time.Second, MaxBodyBytes: 1024, Operations: tt.operations}
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestLimitDuration checks only the operations failing on their deadline are counted as timeouts.
+func TestLimitDuration(t *testing.T) {
+	const timeout = 20 * time.Millisecond
+
+	s := &Service{
+		cfg: Config{
+			Errors: ErrorsConfig{Format: ErrorFormatJSON},
+			Limits: LimitsConfig{Timeout: timeout, MaxBodyBytes: 1024},
+		},
+		logger:  slog.New(slog.DiscardHandler),
+		metrics: testService().metrics,
+	}
+	router := chi.NewRouter()
+	api := s.newHumaAPI(router)
+
+	tests := []struct {
+		name    string
+		handler func(ctx context.Context) error
+		status  int
+		counted float64
+	}{
+		{
+			name:    "within deadline",
+			handler: func(context.Context) error { return nil },
+			status:  http.StatusNoContent,
+		},
+		{
+			name: "succeeded past deadline",
+			handler: func(ctx context.Context) error {
+				<-ctx.Done()
+				return nil
+			},
+			status: http.StatusNoContent,
+		},
+		{
+			name: "failed on deadline",
+			handler: func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+			status:  http.StatusGatewayTimeout,
+			counted: 1,
+		},
+		{
+			name: "failed past deadline with another error",
+			handler: func(ctx context.Context) error {
+				<-ctx.Done()
+				return errors.New("unexpected")
+			},
+			status: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := "limit-duration-" + strings.ReplaceAll(tt.name, " ", "-")
+			path := "/" + id
+			huma.Register(api, huma.Operation{
+				OperationID: id,
+				Method:      http.MethodGet,
+				Path:        path,
+				Middlewares: huma.Middlewares{s.limitDuration},
+			}, func(ctx context.Context, _ *struct{}) (*struct{}, error) {
+				return nil, tt.handler(ctx)
+			})
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d, body %s", rec.Code, tt.status, rec.Body)
+			}
+			got := testutil.ToFloat64(s.metrics.OperationTimeoutsTotal.WithLabelValues(id))
+			if got != tt.counted {
+				t.Errorf("timeouts = %v, want %v", got, tt.counted)
+			}
+		})
+	}
+}
@@ -9,14 +9,24 @@ const (
 	// Path is the path to the metrics endpoint.
 	Path = "/metrics"
 
-	method   = "method"
-	endpoint = "endpoint"
+	method    = "method"
+	endpoint  = "endpoint"
+	rule      = "rule"
+	fault     = "fault"
+	operation = "operation"
+	encoding  = "encoding"
+	result    = "result"
 )
 
 type Metrics struct {
-	RequestsTotal    *prometheus.CounterVec
-	InflightRequests prometheus.Gauge
-	RequestDuration  *prometheus.HistogramVec
+	RequestsTotal          *prometheus.CounterVec
+	InflightRequests       prometheus.Gauge
+	RequestDuration        *prometheus.HistogramVec
+	ChaosFaultsTotal       *prometheus.CounterVec
+	OperationTimeoutsTotal *prometheus.CounterVec
+	CompressionRatio       *prometheus.HistogramVec
+	CompressionDuration    *prometheus.HistogramVec
+	ConditionalRequests    *prometheus.CounterVec
 }
 
 func New() *Metrics {
@@ -34,5 +44,27 @@ func New() *Metrics {
 			Help:    "Histogram of HTTP request durations",
 			Buckets: prometheus.DefBuckets,
 		}, []string{method, endpoint}),
+		ChaosFaultsTotal: promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_chaos_faults_total",
+			Help: "Total number of faults injected by the chaos middleware",
+		}, []string{rule, fault}),
+		OperationTimeoutsTotal: promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_operation_timeouts_total",
+			Help: "Total number of API operations that failed on their deadline",
+		}, []string{operation}),
+		CompressionRatio: promauto.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_response_compression_ratio",
+			Help:    "Histogram of the ratio of the uncompressed to the compressed size of the responses",
+			Buckets: []float64{1, 1.5, 2, 3, 5, 8, 13, 21},
+		}, []string{encoding}),
+		CompressionDuration: promauto.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_response_compression_duration_seconds",
+			Help:    "Histogram of the time spent compressing the responses",
+			Buckets: []float64{.0001, .0005, .001, .005, .01, .05, .1},
+		}, []string{encoding}),
+		ConditionalRequests: promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_conditional_requests_total",
+			Help: "Total number of conditional requests by outcome, e.g. not_modified or precondition_failed",
+		}, []string{operation, result}),
 	}
 }
@@ -0,0 +1,426 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/internal/http/metrics"
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/pkg/config"
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/pkg/zerror"
+)
+
+// Faults injected by [Chaos] after the optional latency.
+const (
+	ChaosFaultNone  = "none"
+	ChaosFaultError = "error"
+	ChaosFaultAbort = "abort"
+	ChaosFaultPanic = "panic"
+
+	// chaosFaultLatency is the fault recorded for the latency of a rule.
+	chaosFaultLatency = "latency"
+)
+
+// Distributions of the latency injected by [Chaos].
+const (
+	ChaosLatencyFixed       = "fixed"
+	ChaosLatencyUniform     = "uniform"
+	ChaosLatencyNormal      = "normal"
+	ChaosLatencyExponential = "exponential"
+)
+
+// maxChaosRules bounds the number of rules, whose names label the chaos metrics.
+const maxChaosRules = 32
+
+var (
+	// chaosRuleNamePattern bounds the names of the rules, which label the chaos metrics.
+	chaosRuleNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+	chaosFaults    = []string{ChaosFaultNone, ChaosFaultError, ChaosFaultAbort, ChaosFaultPanic}
+	chaosLatencies = []string{ChaosLatencyFixed, ChaosLatencyUniform, ChaosLatencyNormal, ChaosLatencyExponential}
+)
+
+// ErrorWriter writes err as the error response of r.
+type ErrorWriter func(w http.ResponseWriter, r *http.Request, err error)
+
+// ChaosRule injects faults into the requests it matches.
+// A request matches when it matches every condition that is set.
+type ChaosRule struct {
+	Name string `yaml:"name" json:"name"`
+	// Route is a chi route pattern such as /api/v1/users/{id}, with a trailing * matching
+	// any rest of the path. An empty route matches every path.
+	Route string `yaml:"route" json:"route,omitempty"`
+	// Methods are the HTTP methods to match. No methods match every method.
+	Methods []string `yaml:"methods" json:"methods,omitempty"`
+	// Headers maps a header to the value it must have. An empty value only requires the header.
+	Headers map[string]string `yaml:"headers" json:"headers,omitempty"`
+	// Probability is the chance from 0 to 1 that a matching request is faulted.
+	Probability float64 `yaml:"probability" json:"probability"`
+	// Latency is added before the fault, if its distribution is set.
+	Latency ChaosLatency `yaml:"latency" json:"latency,omitzero"`
+	// Fault is none (the default), error (respond with Error), abort (close the connection) or panic.
+	Fault string     `yaml:"fault" json:"fault,omitempty" enum:",none,error,abort,panic"`
+	Error ChaosError `yaml:"error" json:"error,omitzero"`
+}
+
+// ChaosLatency is the distribution of an injected latency.
+type ChaosLatency struct {
+	// Distribution is fixed (Mean), uniform (Min to Max), normal (Mean and StdDev)
+	// or exponential (Mean). An empty distribution adds no latency.
+	Distribution string        `yaml:"distribution" enum:",fixed,uniform,normal,exponential"`
+	Min          time.Duration `yaml:"min"`
+	Max          time.Duration `yaml:"max"`
+	Mean         time.Duration `yaml:"mean"`
+	StdDev       time.Duration `yaml:"stddev"`
+}
+
+// ChaosError is the error response of an error fault.
+type ChaosError struct {
+	Status  zerror.Status `yaml:"status" json:"status"`
+	Code    string        `yaml:"code" json:"code"`
+	Message string        `yaml:"message" json:"message"`
+}
+
+func (rule *ChaosRule) Validate() error {
+	var errs []error
+
+	if rule.Name == "" {
+		errs = append(errs, config.Fieldf("name", "is required"))
+	} else if !chaosRuleNamePattern.MatchString(rule.Name) {
+		errs = append(errs, config.Fieldf("name", "must be 1 to 63 lowercase letters, digits, - or _, starting with a letter or digit"))
+	}
+	if rule.Route != "" && !strings.HasPrefix(rule.Route, "/") {
+		errs = append(errs, config.Fieldf("route", "must start with /"))
+	}
+	if rule.Probability < 0 || rule.Probability > 1 {
+		errs = append(errs, config.Fieldf("probability", "must be between 0 and 1"))
+	}
+	if err := rule.Latency.validate(); err != nil {
+		errs = append(errs, config.Field("latency", err))
+	}
+	if rule.Fault != "" && !slices.Contains(chaosFaults, rule.Fault) {
+		errs = append(errs, config.Fieldf("fault", "must be one of %s", strings.Join(chaosFaults, ", ")))
+	}
+	if rule.Fault == ChaosFaultError {
+		if !slices.Contains(zerror.Statuses(), rule.Error.Status) {
+			errs = append(errs, config.Fieldf("error.status", "must be a zerror status such as %q", zerror.StatusServiceUnavailable))
+		}
+		if rule.Error.Code == "" {
+			errs = append(errs, config.Fieldf("error.code", "is required"))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (l *ChaosLatency) validate() error {
+	if l.Distribution == "" {
+		return nil
+	}
+	if !slices.Contains(chaosLatencies, l.Distribution) {
+		return config.Fieldf("distribution", "must be one of %s", strings.Join(chaosLatencies, ", "))
+	}
+
+	var errs []error
+	if l.Min < 0 || l.Max < 0 || l.Mean < 0 || l.StdDev < 0 {
+		errs = append(errs, errors.New("durations must not be negative"))
+	}
+	if l.Distribution == ChaosLatencyUniform && l.Max < l.Min {
+		errs = append(errs, config.Fieldf("max", "must be greater than or equal to min"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// sample returns a latency drawn from the distribution, never negative.
+//
+//nolint:gosec // Fault injection, not used for security.
+func (l *ChaosLatency) sample() time.Duration {
+	var d float64
+	switch l.Distribution {
+	case ChaosLatencyFixed:
+		d = float64(l.Mean)
+	case ChaosLatencyUniform:
+		d = float64(l.Min) + rand.Float64()*float64(l.Max-l.Min)
+	case ChaosLatencyNormal:
+		d = float64(l.Mean) + rand.NormFloat64()*float64(l.StdDev)
+	case ChaosLatencyExponential:
+		d = rand.ExpFloat64() * float64(l.Mean)
+	}
+
+	return time.Duration(math.Max(d, 0))
+}
+
+// chaosLatencyJSON is the JSON form of ChaosLatency, with durations as strings such as "250ms".
+type chaosLatencyJSON struct {
+	Distribution string `json:"distribution,omitempty"`
+	Min          string `json:"min,omitempty"`
+	Max          string `json:"max,omitempty"`
+	Mean         string `json:"mean,omitempty"`
+	StdDev       string `json:"stddev,omitempty"`
+}
+
+func (l ChaosLatency) MarshalJSON() ([]byte, error) {
+	format := func(d time.Duration) string {
+		if d == 0 {
+			return ""
+		}
+		return d.String()
+	}
+
+	return json.Marshal(chaosLatencyJSON{
+		Distribution: l.Distribution,
+		Min:          format(l.Min),
+		Max:          format(l.Max),
+		Mean:         format(l.Mean),
+		StdDev:       format(l.StdDev),
+	})
+}
+
+func (l *ChaosLatency) UnmarshalJSON(b []byte) error {
+	var v chaosLatencyJSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	parse := func(name, s string) (time.Duration, error) {
+		if s == "" {
+			return 0, nil
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", name, err)
+		}
+		return d, nil
+	}
+
+	var errs [4]error
+	l.Distribution = v.Distribution
+	l.Min, errs[0] = parse("min", v.Min)
+	l.Max, errs[1] = parse("max", v.Max)
+	l.Mean, errs[2] = parse("mean", v.Mean)
+	l.StdDev, errs[3] = parse("stddev", v.StdDev)
+
+	return errors.Join(errs[:]...)
+}
+
+// matches reports whether r matches the conditions of the rule.
+func (rule *ChaosRule) matches(r *http.Request) bool {
+	if len(rule.Methods) > 0 && !slices.ContainsFunc(rule.Methods, func(m string) bool {
+		return strings.EqualFold(m, r.Method)
+	}) {
+		return false
+	}
+	for name, value := range rule.Headers {
+		got, ok := r.Header[http.CanonicalHeaderKey(name)]
+		if !ok || (value != "" && !slices.Contains(got, value)) {
+			return false
+		}
+	}
+
+	return rule.Route == "" || matchRoute(rule.Route, r.URL.Path)
+}
+
+// matchRoute reports whether path matches the chi route pattern, where {param} matches
+// one path segment and a trailing * matches the rest of the path.
+// The middleware runs before routing, so the matched route of the request is not known yet.
+func matchRoute(pattern, path string) bool {
+	patternSegments := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathSegments := strings.Split(strings.Trim(path, "/"), "/")
+
+	for i, segment := range patternSegments {
+		if segment == "*" && i == len(patternSegments)-1 {
+			return true
+		}
+		if i >= len(pathSegments) {
+			return false
+		}
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			continue
+		}
+		if segment != pathSegments[i] {
+			return false
+		}
+	}
+
+	return len(patternSegments) == len(pathSegments)
+}
+
+// Chaos is a middleware injecting latency, error responses, aborted connections
+// and panics into the requests matching its rules, to exercise alerts and dashboards.
+// The rules can be replaced while it serves requests.
+// Use [NewChaos] to create a new instance of Chaos.
+type Chaos struct {
+	metrics    *metrics.Metrics
+	writeError ErrorWriter
+	skipPaths  []string
+
+	enabled atomic.Bool
+	rules   atomic.Pointer[[]ChaosRule]
+}
+
+// NewChaos initializes a disabled Chaos without rules.
+// Error faults are written with writeError, so they look like the errors of the handlers.
+// Requests to skipPaths are never faulted, e.g. so the rules can always be changed.
+func NewChaos(m *metrics.Metrics, writeError ErrorWriter, skipPaths ...string) *Chaos {
+	c := &Chaos{
+		metrics:    m,
+		writeError: writeError,
+		skipPaths:  skipPaths,
+	}
+	c.rules.Store(&[]ChaosRule{})
+
+	return c
+}
+
+// Enabled reports whether faults are injected.
+func (c *Chaos) Enabled() bool {
+	return c.enabled.Load()
+}
+
+// SetEnabled enables or disables the injection of faults.
+func (c *Chaos) SetEnabled(enabled bool) {
+	c.enabled.Store(enabled)
+}
+
+// Rules returns the current rules.
+func (c *Chaos) Rules() []ChaosRule {
+	return slices.Clone(*c.rules.Load())
+}
+
+// SetRules validates rules and replaces the current rules with them.
+func (c *Chaos) SetRules(rules []ChaosRule) error {
+	if err := ValidateChaosRules(rules); err != nil {
+		return err
+	}
+
+	rules = append([]ChaosRule{}, rules...)
+	old := c.rules.Swap(&rules)
+
+	// Forget the series of the removed rules, so replacing rules does not grow the metrics.
+	for _, rule := range *old {
+		if !slices.ContainsFunc(rules, func(r ChaosRule) bool { return r.Name == rule.Name }) {
+			c.metrics.ChaosFaultsTotal.DeletePartialMatch(prometheus.Labels{"rule": rule.Name})
+		}
+	}
+
+	return nil
+}
+
+// ValidateChaosRules validates every rule, reporting the errors under rules.<index>.
+// The names of the rules must be unique.
+func ValidateChaosRules(rules []ChaosRule) error {
+	var errs []error
+	if len(rules) > maxChaosRules {
+		errs = append(errs, config.Fieldf("rules", "must have at most %d rules", maxChaosRules))
+	}
+
+	names := make(map[string]bool, len(rules))
+	for i := range rules {
+		if name := rules[i].Name; name != "" {
+			if names[name] {
+				errs = append(errs, config.Field(fmt.Sprintf("rules.%d", i), config.Fieldf("name", "must be unique, %q is already used", name)))
+			}
+			names[name] = true
+		}
+
+		err := rules[i].Validate()
+		if err == nil {
+			continue
+		}
+
+		// Prefix every error of the rule, not only the first line of the joined errors.
+		ruleErrs := []error{err}
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			ruleErrs = joined.Unwrap()
+		}
+		for _, err := range ruleErrs {
+			errs = append(errs, config.Field(fmt.Sprintf("rules.%d", i), err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Handler injects the faults of the first rule matching the request that triggers.
+func (c *Chaos) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !c.enabled.Load() || slices.Contains(c.skipPaths, r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		for _, rule := range *c.rules.Load() {
+			//nolint:gosec // Fault injection, not used for security.
+			if rule.matches(r) && rand.Float64() < rule.Probability {
+				if !c.inject(w, r, &rule) {
+					return
+				}
+				break
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// inject injects the faults of rule and reports whether the request should still be served.
+func (c *Chaos) inject(w http.ResponseWriter, r *http.Request, rule *ChaosRule) bool {
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	if rule.Latency.Distribution != "" {
+		latency := rule.Latency.sample()
+		c.mark(span, rule.Name, chaosFaultLatency, attribute.Int64("chaos.latency_ms", latency.Milliseconds()))
+
+		timer := time.NewTimer(latency)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		case <-timer.C:
+		}
+	}
+
+	switch rule.Fault {
+	case ChaosFaultError:
+		c.mark(span, rule.Name, ChaosFaultError)
+		c.writeError(w, r, zerror.NewZError(nil, rule.Error.Status, rule.Error.Code, rule.Error.Message))
+		return false
+	case ChaosFaultAbort:
+		c.mark(span, rule.Name, ChaosFaultAbort)
+		// The server closes the connection without a response and does not log the panic.
+		panic(http.ErrAbortHandler)
+	case ChaosFaultPanic:
+		c.mark(span, rule.Name, ChaosFaultPanic)
+		panic(fmt.Sprintf("chaos: panic injected by rule %q", rule.Name))
+	default:
+		return true
+	}
+}
+
+// mark records an injected fault on the span and in the metrics.
+func (c *Chaos) mark(span trace.Span, rule, fault string, attrs ...attribute.KeyValue) {
+	span.SetAttributes(
+		attribute.String("chaos.rule", rule),
+		attribute.String("chaos.fault", fault),
+	)
+	span.AddEvent("chaos fault injected", trace.WithAttributes(append(attrs,
+		attribute.String("chaos.rule", rule),
+		attribute.String("chaos.fault", fault),
+	)...))
+
+	c.metrics.ChaosFaultsTotal.WithLabelValues(rule, fault).Inc()
+}
@@ -0,0 +1,379 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/internal/http/metrics"
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/pkg/config"
+)
+
+// Content encodings supported by Compress and Decompress.
+const (
+	EncodingGzip   = "gzip"
+	EncodingZstd   = "zstd"
+	EncodingBrotli = "br"
+)
+
+var encodings = []string{EncodingZstd, EncodingBrotli, EncodingGzip}
+
+// CompressConfig configures the compression of the responses.
+type CompressConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Encodings are the encodings responses are compressed with, in order of preference
+	// when the client accepts several of them equally.
+	Encodings []string `yaml:"encodings"`
+	// MinSize is the size in bytes from which responses are compressed.
+	// Smaller responses are not worth the overhead.
+	MinSize int `yaml:"min_size"`
+	// ContentTypes are the media types of the responses that are compressed, e.g. application/json.
+	// A type ending with /* matches all its subtypes, e.g. text/*.
+	ContentTypes []string `yaml:"content_types"`
+}
+
+func (c *CompressConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	var errs []error
+	if len(c.Encodings) == 0 {
+		errs = append(errs, config.Fieldf("encodings", "is required"))
+	}
+	for i, encoding := range c.Encodings {
+		if !slices.Contains(encodings, encoding) {
+			errs = append(errs, config.Fieldf(fmt.Sprintf("encodings.%d", i), "must be one of %s", strings.Join(encodings, ", ")))
+		}
+	}
+	if c.MinSize < 0 {
+		errs = append(errs, config.Fieldf("min_size", "must not be negative"))
+	}
+	if len(c.ContentTypes) == 0 {
+		errs = append(errs, config.Fieldf("content_types", "is required"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// compressor is a pooled encoder writing to the response.
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+var compressorPools = map[string]*sync.Pool{
+	EncodingGzip: {New: func() any {
+		return gzip.NewWriter(nil)
+	}},
+	EncodingZstd: {New: func() any {
+		// The options are valid, so NewWriter cannot fail.
+		enc, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		return enc
+	}},
+	EncodingBrotli: {New: func() any {
+		return brotli.NewWriterLevel(nil, brotli.DefaultCompression)
+	}},
+}
+
+// Compress is a middleware compressing the responses with the encoding negotiated
+// with the Accept-Encoding header of the request. Responses smaller than the minimum size,
+// of other content types or already encoded are sent as they are.
+// The compression ratio and time are recorded in m.
+func Compress(cfg CompressConfig, m *metrics.Metrics) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !cfg.Enabled {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"), cfg.Encodings)
+			if encoding == "" || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{
+				ResponseWriter: w,
+				cfg:            cfg,
+				metrics:        m,
+				encoding:       encoding,
+			}
+			defer cw.close()
+
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// negotiateEncoding returns the encoding of supported with the highest weight in
+// the Accept-Encoding header, or "" if none is acceptable.
+func negotiateEncoding(acceptEncoding string, supported []string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+
+	weights := make(map[string]float64)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		weight := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			weight = parsed
+		}
+		weights[strings.ToLower(strings.TrimSpace(name))] = weight
+	}
+
+	best, bestWeight := "", 0.0
+	for _, encoding := range supported {
+		weight, ok := weights[encoding]
+		if !ok {
+			weight = weights["*"]
+		}
+		// Earlier encodings win ties, following the order of preference.
+		if weight > bestWeight {
+			best, bestWeight = encoding, weight
+		}
+	}
+
+	return best
+}
+
+// compressWriter buffers the response until it reaches the minimum size, then
+// compresses it if its content type is compressible.
+type compressWriter struct {
+	http.ResponseWriter
+	cfg      CompressConfig
+	metrics  *metrics.Metrics
+	encoding string
+
+	status      int
+	wroteHeader bool
+	buf         bytes.Buffer
+	// decided is set once the response is known to be compressed or not.
+	decided bool
+	enc     compressor
+	counter countingWriter
+	encTime time.Duration
+	in      int
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+	cw.status = status
+
+	// Informational responses are sent right away.
+	if status < http.StatusOK {
+		cw.wroteHeader = false
+		cw.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+
+	if !cw.decided {
+		cw.buf.Write(p)
+		if cw.buf.Len() < cw.cfg.MinSize {
+			return len(p), nil
+		}
+		if err := cw.decide(false); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+
+	if cw.enc == nil {
+		return cw.ResponseWriter.Write(p)
+	}
+
+	return cw.compress(p)
+}
+
+// decide writes the header, compressed or not, followed by the buffered response.
+// force compresses a compressible response regardless of its size.
+func (cw *compressWriter) decide(force bool) error {
+	cw.decided = true
+
+	h := cw.Header()
+	// A 304 carries the ETag of the response it stands for, weakened only if that
+	// response would be compressed.
+	if cw.status == http.StatusNotModified && cw.notModifiedCompressible() {
+		weakenETag(h)
+	}
+	if cw.compressible(force) {
+		h.Set("Content-Encoding", cw.encoding)
+		h.Del("Content-Length")
+		// Strong validators of the uncompressed response do not apply to the compressed one.
+		weakenETag(h)
+
+		cw.counter = countingWriter{w: cw.ResponseWriter}
+		cw.enc = compressorPools[cw.encoding].Get().(compressor)
+		cw.enc.Reset(&cw.counter)
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+	if cw.buf.Len() == 0 {
+		return nil
+	}
+
+	var err error
+	if cw.enc != nil {
+		_, err = cw.compress(cw.buf.Bytes())
+	} else {
+		_, err = cw.ResponseWriter.Write(cw.buf.Bytes())
+	}
+	cw.buf.Reset()
+
+	return err
+}
+
+func weakenETag(h http.Header) {
+	if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		h.Set("ETag", "W/"+etag)
+	}
+}
+
+func (cw *compressWriter) compressible(force bool) bool {
+	if cw.status < http.StatusOK || cw.status == http.StatusNoContent ||
+		cw.status == http.StatusNotModified || cw.status == http.StatusPartialContent {
+		return false
+	}
+
+	return cw.compressibleContent(cw.buf.Len(), force)
+}
+
+// notModifiedCompressible reports whether the 200 response a 304 stands for would be
+// compressed. Its size is only known from the Content-Length a handler may send with
+// the 304 (RFC 9110 §8.6), so without one the ETag is left as the handler set it.
+func (cw *compressWriter) notModifiedCompressible() bool {
+	size, err := strconv.Atoi(cw.Header().Get("Content-Length"))
+	if err != nil {
+		return false
+	}
+
+	return cw.compressibleContent(size, false)
+}
+
+// compressibleContent reports whether a response of size bytes is compressed given
+// its headers. force compresses it regardless of its size.
+func (cw *compressWriter) compressibleContent(size int, force bool) bool {
+	h := cw.Header()
+	if h.Get("Content-Encoding") != "" || (!force && size < cw.cfg.MinSize) {
+		return false
+	}
+
+	mediaType, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	for _, ct := range cw.cfg.ContentTypes {
+		if prefix, ok := strings.CutSuffix(ct, "/*"); ok {
+			if strings.HasPrefix(mediaType, prefix+"/") {
+				return true
+			}
+		} else if mediaType == ct {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (cw *compressWriter) compress(p []byte) (int, error) {
+	start := time.Now()
+	n, err := cw.enc.Write(p)
+	cw.encTime += time.Since(start)
+	cw.in += n
+
+	return n, err
+}
+
+// Flush sends the buffered response, compressed if it is compressible, and flushes the encoder.
+func (cw *compressWriter) Flush() {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if !cw.decided {
+		// A streamed response is compressed regardless of its size so far.
+		_ = cw.decide(true)
+	}
+	if cw.enc != nil {
+		_ = cw.enc.Flush()
+	}
+
+	_ = http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// Hijack lets websocket upgrades through.
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(cw.ResponseWriter).Hijack()
+}
+
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// close sends the rest of the response and records the compression metrics.
+func (cw *compressWriter) close() {
+	if !cw.wroteHeader {
+		// The handler wrote nothing, e.g. it hijacked the connection.
+		return
+	}
+	if !cw.decided {
+		_ = cw.decide(false)
+	}
+	if cw.enc == nil {
+		return
+	}
+
+	start := time.Now()
+	_ = cw.enc.Close()
+	cw.encTime += time.Since(start)
+
+	cw.enc.Reset(nil)
+	compressorPools[cw.encoding].Put(cw.enc)
+	cw.enc = nil
+
+	cw.metrics.CompressionDuration.WithLabelValues(cw.encoding).Observe(cw.encTime.Seconds())
+	if cw.counter.n > 0 {
+		cw.metrics.CompressionRatio.WithLabelValues(cw.encoding).Observe(float64(cw.in) / float64(cw.counter.n))
+	}
+}
+
+// countingWriter counts the compressed bytes.
+type countingWriter struct {
+	w io.Writer
+	n int
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += n
+	return n, err
+}
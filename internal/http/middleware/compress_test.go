@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/internal/http/metrics"
+)
+
+// testMetrics is shared by the tests, since the metrics are registered globally.
+var testMetrics = metrics.New()
+
+func TestCompressETag(t *testing.T) {
+	cfg := CompressConfig{
+		Enabled:      true,
+		Encodings:    []string{EncodingGzip},
+		MinSize:      1024,
+		ContentTypes: []string{"application/json"},
+	}
+	small := `{"name":"John Doe"}`
+	large := `{"name":"` + strings.Repeat("a", 2048) + `"}`
+
+	tests := []struct {
+		name   string
+		status int
+		header http.Header
+		body   string
+		want   string
+	}{
+		{name: "compressed", status: http.StatusOK, body: large, want: `W/"v1"`},
+		{name: "below min size", status: http.StatusOK, body: small, want: `"v1"`},
+		{
+			name:   "not modified without size",
+			status: http.StatusNotModified,
+			want:   `"v1"`,
+		},
+		{
+			name:   "not modified below min size",
+			status: http.StatusNotModified,
+			header: http.Header{"Content-Length": {strconv.Itoa(len(small))}},
+			want:   `"v1"`,
+		},
+		{
+			name:   "not modified compressed",
+			status: http.StatusNotModified,
+			header: http.Header{"Content-Length": {strconv.Itoa(len(large))}},
+			want:   `W/"v1"`,
+		},
+		{
+			name:   "not modified of other content type",
+			status: http.StatusNotModified,
+			header: http.Header{"Content-Length": {strconv.Itoa(len(large))}, "Content-Type": {"image/png"}},
+			want:   `"v1"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := Compress(cfg, testMetrics)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("ETag", `"v1"`)
+				for name, values := range tt.header {
+					w.Header()[name] = values
+				}
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if got := rec.Header().Get("ETag"); got != tt.want {
+				t.Errorf("ETag = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,17 +2,27 @@ package middleware
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/go-chi/cors"
 )
 
 // Cors is a middleware handler that sets the CORS configuration.
-func Cors() func(http.Handler) http.Handler {
+// allowedOrigins is consulted on every request so the origins can be changed at runtime;
+// an entry of "*" allows any origin.
+func Cors(allowedOrigins func() []string) func(http.Handler) http.Handler {
 	opts := cors.Options{
-		AllowedOrigins:   []string{"*"},
+		AllowOriginFunc: func(_ *http.Request, origin string) bool {
+			for _, o := range allowedOrigins() {
+				if o == "*" || strings.EqualFold(o, origin) {
+					return true
+				}
+			}
+			return false
+		},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type"},
-		ExposedHeaders:   []string{},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "If-Match", "If-None-Match"},
+		ExposedHeaders:   []string{"ETag"},
 		AllowCredentials: false,
 		MaxAge:           86400,
 	}
@@ -0,0 +1,139 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/internal/apperr"
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/pkg/config"
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/pkg/zerror"
+)
+
+// DecompressConfig configures the decompression of the request bodies.
+type DecompressConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// MaxBytes is the maximum decompressed size of a request body, so a small compressed
+	// body cannot expand into gigabytes (a zip bomb).
+	MaxBytes int64 `yaml:"max_bytes"`
+}
+
+func (c *DecompressConfig) Validate() error {
+	if c.Enabled && c.MaxBytes <= 0 {
+		return config.Fieldf("max_bytes", "must be greater than 0")
+	}
+
+	return nil
+}
+
+// Decompress is a middleware decompressing the request bodies encoded with gzip, zstd or br,
+// as given by their Content-Encoding header, so the handlers read them as they are.
+// Unsupported encodings are rejected with 415, and the errors of the bodies that are
+// invalid or exceed the maximum decompressed size are returned by their reads.
+func Decompress(cfg DecompressConfig, writeError ErrorWriter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !cfg.Enabled {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+			if encoding == "" || encoding == "identity" || r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body, err := newDecompressReader(encoding, r.Body, cfg.MaxBytes)
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+			defer body.Close()
+
+			r.Body = body
+			r.Header.Del("Content-Encoding")
+			r.Header.Del("Content-Length")
+			r.ContentLength = -1
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// decompressReader reads the decompressed body, failing once it exceeds the maximum size.
+type decompressReader struct {
+	r        io.Reader
+	close    func() error
+	body     io.Closer
+	maxBytes int64
+	n        int64
+}
+
+func newDecompressReader(encoding string, body io.ReadCloser, maxBytes int64) (*decompressReader, error) {
+	dr := &decompressReader{body: body, maxBytes: maxBytes, close: func() error { return nil }}
+
+	switch encoding {
+	case EncodingGzip:
+		zr, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, invalidBodyError(err)
+		}
+		dr.r, dr.close = zr, zr.Close
+	case EncodingZstd:
+		zr, err := zstd.NewReader(body,
+			zstd.WithDecoderConcurrency(1),
+			zstd.WithDecoderMaxMemory(uint64(maxBytes)),
+		)
+		if err != nil {
+			return nil, invalidBodyError(err)
+		}
+		dr.r = zr
+		dr.close = func() error {
+			zr.Close()
+			return nil
+		}
+	case EncodingBrotli:
+		dr.r = brotli.NewReader(body)
+	default:
+		unsupportedErr := zerror.WithMsg(*apperr.UnsupportedContentEncoding,
+			fmt.Sprintf("Unsupported content encoding %q", encoding))
+		return nil, &unsupportedErr
+	}
+
+	return dr, nil
+}
+
+func (dr *decompressReader) Read(p []byte) (int, error) {
+	// Read one byte more than allowed to tell a body of exactly the maximum size from a larger one.
+	if remaining := dr.maxBytes + 1 - dr.n; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+
+	n, err := dr.r.Read(p)
+	dr.n += int64(n)
+	if dr.n > dr.maxBytes {
+		tooLargeErr := zerror.WithMsg(*apperr.RequestBodyTooLarge,
+			fmt.Sprintf("Decompressed request body is larger than %d bytes", dr.maxBytes))
+		return n - int(dr.n-dr.maxBytes), &tooLargeErr
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return n, invalidBodyError(err)
+	}
+
+	return n, err
+}
+
+func (dr *decompressReader) Close() error {
+	return errors.Join(dr.close(), dr.body.Close())
+}
+
+func invalidBodyError(err error) error {
+	invalidErr := zerror.WithParent(*apperr.InvalidCompressedBody, err)
+	return &invalidErr
+}
@@ -31,7 +31,7 @@ func Logger(logger *slog.Logger) func(http.Handler) http.Handler {
 					slog.Int("status", ww.Status()),          // Status code
 					slog.String("method", r.Method),          // HTTP method
 					slog.String("path", r.URL.Path),          // Request URI
-					slog.String("query", r.URL.RawQuery),     // Request query string
+					slog.Any("query", r.URL.Query()),         // Request query, redacted by the log handler
 					slog.String("remote_ip", r.RemoteAddr),   // IP address
 					slog.String("host", r.Host),              // Host
 					slog.String("user_agent", r.UserAgent()), // User agent
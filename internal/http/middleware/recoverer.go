@@ -1,13 +1,13 @@
 package middleware
 
 import (
-	"encoding/json"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"runtime/debug"
 
 	"github.com/tuanvumaihuynh/victoria-o11y-lab/internal/apperr"
-	"github.com/tuanvumaihuynh/victoria-o11y-lab/internal/http/dto"
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/pkg/zerror"
 )
 
 // Recoverer is a middleware that recovers from panics, logs the panic (and a
@@ -15,12 +15,8 @@ import (
 // possible.
 //
 // Recoverer prints a stack trace of the last function call.
-func Recoverer(log *slog.Logger) func(http.Handler) http.Handler {
-	res := &dto.ErrorResponse{
-		Code:    apperr.InternalServerErr.Code(),
-		Message: apperr.InternalServerErr.Msg(),
-	}
-
+// The response is written with writeError, in the same format as the other error responses.
+func Recoverer(log *slog.Logger, writeError ErrorWriter) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			defer func() {
@@ -35,11 +31,9 @@ func Recoverer(log *slog.Logger) func(http.Handler) http.Handler {
 						slog.String("stack", string(debug.Stack())))
 
 					if r.Header.Get("Connection") != "Upgrade" {
-						w.Header().Set("Content-Type", "application/json")
-						w.WriteHeader(http.StatusInternalServerError)
-						if err := json.NewEncoder(w).Encode(res); err != nil {
-							log.ErrorContext(r.Context(), "error encoding response", slog.Any("error", err))
-						}
+						// The panic is only shown by the error responses in debug mode.
+						panicErr := zerror.WithParent(*apperr.InternalServerErr, fmt.Errorf("panic: %v", rvr))
+						writeError(w, r, &panicErr)
 					}
 				}
 			}()
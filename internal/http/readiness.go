@@ -0,0 +1,70 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// ReadinessPath is the path to the readiness endpoint.
+const ReadinessPath = "/readyz"
+
+// readinessTimeout bounds the time all readiness checks may take together.
+const readinessTimeout = 5 * time.Second
+
+// ReadinessCheck reports whether a dependency of the service is ready, returning an error if not.
+type ReadinessCheck func(ctx context.Context) error
+
+type readinessCheck struct {
+	name  string
+	check ReadinessCheck
+}
+
+type readinessResponse struct {
+	Status string                 `json:"status"`
+	Checks []readinessCheckResult `json:"checks"`
+}
+
+type readinessCheckResult struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// AddReadinessCheck adds a check to the readiness endpoint.
+// The service is ready when all of its checks pass. It must be called before Run.
+func (s *Service) AddReadinessCheck(name string, check ReadinessCheck) {
+	s.readinessChecks = append(s.readinessChecks, readinessCheck{name: name, check: check})
+}
+
+// readinessHandler runs the readiness checks and responds with 503 Service Unavailable
+// if any of them fails.
+func (s *Service) readinessHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	resp := readinessResponse{
+		Status: "ready",
+		Checks: make([]readinessCheckResult, 0, len(s.readinessChecks)),
+	}
+	statusCode := http.StatusOK
+
+	for _, c := range s.readinessChecks {
+		result := readinessCheckResult{Name: c.name, Status: "pass"}
+		if err := c.check(ctx); err != nil {
+			result.Status = "fail"
+			result.Error = err.Error()
+			resp.Status = "not_ready"
+			statusCode = http.StatusServiceUnavailable
+		}
+		resp.Checks = append(resp.Checks, result)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		s.logger.ErrorContext(ctx, "error writing readiness response", slog.Any("error", err))
+	}
+}
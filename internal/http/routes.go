@@ -10,13 +10,19 @@ import (
 
 func (s *Service) RegisterRoutes(api huma.API) {
 	group := huma.NewGroup(api, "/api/v1")
+	group.UseMiddleware(s.limitDuration)
 
-	registerHandler(group, http.MethodPost, "/users", s.CreateUser, CreateUserDocs())
-	registerHandler(group, http.MethodGet, "/users/{id}", s.GetUserByID, GetUserByIDDocs())
+	registerHandler(group, s.cfg.Limits, http.MethodPost, "/users", s.CreateUser, CreateUserDocs())
+	registerHandler(group, s.cfg.Limits, http.MethodGet, "/users", s.ListUsers, ListUsersDocs())
+	registerHandler(group, s.cfg.Limits, http.MethodGet, "/users/{id}", s.GetUserByID, GetUserByIDDocs())
+	registerHandler(group, s.cfg.Limits, http.MethodPatch, "/users/{id}", s.UpdateUser, UpdateUserDocs())
+	registerHandler(group, s.cfg.Limits, http.MethodDelete, "/users/{id}", s.DeleteUser, DeleteUserDocs())
+	registerHandler(group, s.cfg.Limits, http.MethodGet, "/errors", s.ListErrors, ListErrorsDocs())
 }
 
 func registerHandler[I any, O any](
 	humaAPI huma.API,
+	limits LimitsConfig,
 	method string,
 	path string,
 	handler func(ctx context.Context, req *I) (*O, error),
@@ -24,6 +30,9 @@ func registerHandler[I any, O any](
 ) {
 	op.Method = method
 	op.Path = path
+	op.Errors = operationErrorStatuses(op.OperationID)
+	// The deadline is applied by the limitDuration middleware.
+	op.MaxBodyBytes = limits.operation(op.OperationID).MaxBodyBytes
 	huma.Register(humaAPI, op, func(ctx context.Context, req *I) (*O, error) {
 		output, err := handler(ctx, req)
 		if err != nil {
@@ -2,11 +2,15 @@ package http
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"log/slog"
+	"maps"
 	"net"
 	"net/http"
+	"slices"
+	"sync/atomic"
 	"time"
 
 	"github.com/danielgtaylor/huma/v2"
@@ -18,49 +22,154 @@ import (
 
 	"github.com/tuanvumaihuynh/victoria-o11y-lab/internal/http/metrics"
 	"github.com/tuanvumaihuynh/victoria-o11y-lab/internal/http/middleware"
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/pkg/config"
 )
 
 var tracer = otel.Tracer("internal/http")
 
 type Config struct {
-	Port           uint `yaml:"port"`
-	SwaggerEnabled bool `yaml:"swagger_enabled"`
+	Port           uint                        `yaml:"port"`
+	SwaggerEnabled bool                        `yaml:"swagger_enabled"`
+	Cors           CorsConfig                  `yaml:"cors"`
+	Errors         ErrorsConfig                `yaml:"errors"`
+	Server         ServerConfig                `yaml:"server"`
+	Limits         LimitsConfig                `yaml:"limits"`
+	Compression    middleware.CompressConfig   `yaml:"compression"`
+	Decompression  middleware.DecompressConfig `yaml:"decompression"`
+	Chaos          ChaosConfig                 `yaml:"chaos"`
+}
+
+type CorsConfig struct {
+	AllowedOrigins []string `yaml:"allowed_origins"`
+}
+
+// ChaosConfig configures the injection of faults into matching requests.
+type ChaosConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// AdminToken enables the chaos admin endpoint, to view and replace the rules at runtime.
+	// Requests to it must send the token as a bearer token.
+	AdminToken string                 `yaml:"admin_token" secret:"true"`
+	Rules      []middleware.ChaosRule `yaml:"rules"`
+}
+
+func (c *ChaosConfig) Validate() error {
+	return middleware.ValidateChaosRules(c.Rules)
 }
 
 func (h *Config) Validate() error {
+	var errs []error
 	if h.Port == 0 {
-		return fmt.Errorf("port is required")
+		errs = append(errs, config.Fieldf("port", "is required"))
 	}
 
-	return nil
+	if h.Server.WriteTimeout > 0 {
+		timeouts := map[string]time.Duration{"limits.timeout": h.Limits.Timeout}
+		for id, limits := range h.Limits.Operations {
+			timeouts["limits.operations."+id+".timeout"] = limits.Timeout
+		}
+		for _, key := range slices.Sorted(maps.Keys(timeouts)) {
+			if timeouts[key] >= h.Server.WriteTimeout {
+				errs = append(errs, config.Fieldf(key, "must be less than server.write_timeout"))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
 }
 
 type Service struct {
 	cfg     Config
 	logger  *slog.Logger
 	metrics *metrics.Metrics
+
+	allowedOrigins  atomic.Pointer[[]string]
+	readinessChecks []readinessCheck
+	chaos           *middleware.Chaos
 }
 
 type CleanupFunc func(ctx context.Context) error
 
 func New(cfg Config, logger *slog.Logger) *Service {
-	return &Service{
+	s := &Service{
 		cfg:     cfg,
 		logger:  logger.With(slog.String("service", "http")),
 		metrics: metrics.New(),
 	}
+	s.allowedOrigins.Store(&cfg.Cors.AllowedOrigins)
+	s.chaos = middleware.NewChaos(s.metrics, s.writeError, metrics.Path, ReadinessPath, ChaosAdminPath)
+
+	return s
+}
+
+// Reload applies the settings of cfg that are safe to change while the service is running:
+// the CORS allowed origins and the chaos rules. Rules set with the chaos admin endpoint are replaced.
+func (s *Service) Reload(cfg Config) error {
+	s.allowedOrigins.Store(&cfg.Cors.AllowedOrigins)
+
+	if err := s.chaos.SetRules(cfg.Chaos.Rules); err != nil {
+		return fmt.Errorf("set chaos rules: %w", err)
+	}
+	s.chaos.SetEnabled(cfg.Chaos.Enabled)
+
+	return nil
 }
 
 func (s *Service) Run(ctx context.Context) (CleanupFunc, error) {
+	if err := s.chaos.SetRules(s.cfg.Chaos.Rules); err != nil {
+		return nil, fmt.Errorf("set chaos rules: %w", err)
+	}
+	s.chaos.SetEnabled(s.cfg.Chaos.Enabled)
+
+	handler, err := s.handler()
+	if err != nil {
+		return nil, err
+	}
+
+	srv := &http.Server{
+		Addr:              fmt.Sprintf(":%d", s.cfg.Port),
+		Handler:           handler,
+		ReadTimeout:       s.cfg.Server.ReadTimeout,
+		WriteTimeout:      s.cfg.Server.WriteTimeout,
+		ReadHeaderTimeout: s.cfg.Server.ReadHeaderTimeout,
+		IdleTimeout:       s.cfg.Server.IdleTimeout,
+		MaxHeaderBytes:    1 << 16, // 64 KB
+		BaseContext: func(_ net.Listener) context.Context {
+			return ctx
+		},
+	}
+
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			panic(fmt.Errorf("http service error listening and serving: %w", err))
+		}
+	}()
+
+	return func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, s.cfg.Server.ShutdownTimeout)
+		defer cancel()
+		return srv.Shutdown(ctx)
+	}, nil
+}
+
+// handler returns the handler of the service: the middlewares, the API and the
+// endpoints outside of it.
+func (s *Service) handler() (http.Handler, error) {
 	r := chi.NewRouter()
 
 	r.Use(
-		middleware.Recoverer(s.logger),
 		middleware.CorrelationID(),
 		middleware.Trace(tracer),
 		middleware.Metrics(s.metrics),
 		middleware.Logger(s.logger),
-		middleware.Cors(),
+		// The Recoverer comes after the middlewares above, so the 500 responses of panics
+		// carry the trace and correlation IDs, and are traced, counted and logged.
+		middleware.Recoverer(s.logger, s.renderError),
+		middleware.Compress(s.cfg.Compression, s.metrics),
+		middleware.Decompress(s.cfg.Decompression, s.writeError),
+		middleware.Cors(func() []string {
+			return *s.allowedOrigins.Load()
+		}),
+		s.chaos.Handler,
 	)
 
 	// Add metrics endpoint
@@ -68,6 +177,12 @@ func (s *Service) Run(ctx context.Context) (CleanupFunc, error) {
 		ErrorLog: log.Default(),
 	}))
 
+	r.Get(ReadinessPath, s.readinessHandler)
+
+	if s.cfg.Chaos.AdminToken != "" {
+		s.registerChaosAdmin(r)
+	}
+
 	api := s.newHumaAPI(r)
 
 	s.RegisterRoutes(api)
@@ -78,35 +193,12 @@ func (s *Service) Run(ctx context.Context) (CleanupFunc, error) {
 		}
 	}
 
-	srv := &http.Server{
-		Addr:              fmt.Sprintf(":%d", s.cfg.Port),
-		Handler:           r,
-		ReadTimeout:       10 * time.Second,
-		WriteTimeout:      10 * time.Second,
-		ReadHeaderTimeout: 5 * time.Second,
-		IdleTimeout:       120 * time.Second,
-		MaxHeaderBytes:    1 << 16, // 64 KB
-		BaseContext: func(_ net.Listener) context.Context {
-			return ctx
-		},
-	}
-
-	go func() {
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			panic(fmt.Errorf("http service error listening and serving: %w", err))
-		}
-	}()
-
-	return func(ctx context.Context) error {
-		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
-		defer cancel()
-		return srv.Shutdown(ctx)
-	}, nil
+	return r, nil
 }
 
 func (s *Service) newHumaAPI(r *chi.Mux) huma.API {
-	huma.NewError = newHumaError(s.logger)
-	huma.NewErrorWithContext = newHumaErrorWithContext(s.logger)
+	huma.NewError = newHumaError(s.logger, s.cfg.Errors)
+	huma.NewErrorWithContext = newHumaErrorWithContext(s.logger, s.cfg.Errors)
 
 	cfg := huma.DefaultConfig("Victoria O11y Lab API", "1.0.0")
 
@@ -117,6 +209,7 @@ func (s *Service) newHumaAPI(r *chi.Mux) huma.API {
 	cfg.DocsPath = ""
 
 	api := humachi.New(r, cfg)
+	api.OpenAPI().OnAddOperation = append(api.OpenAPI().OnAddOperation, s.documentErrors)
 
 	return api
 }
@@ -3,16 +3,18 @@ package http
 import (
 	"context"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/danielgtaylor/huma/v2"
 
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/internal/apperr"
 	"github.com/tuanvumaihuynh/victoria-o11y-lab/internal/http/dto"
 )
 
 func CreateUserDocs() huma.Operation {
 	return huma.Operation{
-		OperationID:   "create-user",
+		OperationID:   apperr.OpCreateUser,
 		Summary:       "Create a new user",
 		Description:   "Create a new user with the given name and email",
 		DefaultStatus: http.StatusCreated,
@@ -33,25 +35,121 @@ func (s *Service) CreateUser(ctx context.Context, req *dto.CreateUserRequest) (*
 	}, nil
 }
 
+// stubUserUpdatedAt is the last update of the stub user, fixed so its ETag is stable.
+var stubUserUpdatedAt = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
 func GetUserByIDDocs() huma.Operation {
 	return huma.Operation{
-		OperationID:   "get-user-by-id",
+		OperationID:   apperr.OpGetUserByID,
 		Summary:       "Get a user by ID",
-		Description:   "Get a user by ID with the given ID",
+		Description:   "Get a user by ID with the given ID. Send the ETag of a cached user in If-None-Match to get 304 Not Modified if it has not changed.",
 		DefaultStatus: http.StatusOK,
 		Tags:          []string{"users"},
+		Responses: map[string]*huma.Response{
+			strconv.Itoa(http.StatusNotModified): {Description: "The user matches If-None-Match"},
+		},
 	}
 }
 
 func (s *Service) GetUserByID(ctx context.Context, req *dto.GetUserByIDRequest) (*dto.GetUserByIDResponse, error) {
-	now := time.Now()
+	user := dto.GetUserByIDResponseBody{
+		ID:        req.ID,
+		Name:      "John Doe",
+		Email:     "john.doe@example.com",
+		CreatedAt: stubUserUpdatedAt,
+		UpdatedAt: stubUserUpdatedAt,
+	}
+
+	tag := etag(user.UpdatedAt)
+	if s.notModified(apperr.OpGetUserByID, req.IfNoneMatch, tag) {
+		return &dto.GetUserByIDResponse{Status: http.StatusNotModified, ETag: tag}, nil
+	}
+
 	return &dto.GetUserByIDResponse{
-		Body: dto.GetUserByIDResponseBody{
-			ID:        req.ID,
-			Name:      "John Doe",
-			Email:     "john.doe@example.com",
-			CreatedAt: now,
-			UpdatedAt: now,
+		Status: http.StatusOK,
+		ETag:   tag,
+		Body:   user,
+	}, nil
+}
+
+func UpdateUserDocs() huma.Operation {
+	return huma.Operation{
+		OperationID:   apperr.OpUpdateUser,
+		Summary:       "Update a user",
+		Description:   "Update the name or email of a user. The ETag of the user must be sent in If-Match, so updates based on a stale copy fail.",
+		DefaultStatus: http.StatusOK,
+		Tags:          []string{"users"},
+	}
+}
+
+func (s *Service) UpdateUser(ctx context.Context, req *dto.UpdateUserRequest) (*dto.UpdateUserResponse, error) {
+	if err := s.checkIfMatch(apperr.OpUpdateUser, req.IfMatch, etag(stubUserUpdatedAt)); err != nil {
+		return nil, err
+	}
+
+	user := dto.UpdateUserResponseBody{
+		ID:        req.ID,
+		Name:      "John Doe",
+		Email:     "john.doe@example.com",
+		CreatedAt: stubUserUpdatedAt,
+		UpdatedAt: time.Now(),
+	}
+	if req.Body.Name != nil {
+		user.Name = *req.Body.Name
+	}
+	if req.Body.Email != nil {
+		user.Email = *req.Body.Email
+	}
+
+	return &dto.UpdateUserResponse{
+		ETag: etag(user.UpdatedAt),
+		Body: user,
+	}, nil
+}
+
+func DeleteUserDocs() huma.Operation {
+	return huma.Operation{
+		OperationID:   apperr.OpDeleteUser,
+		Summary:       "Delete a user",
+		Description:   "Delete a user. The ETag of the user must be sent in If-Match, so a user changed since it was read is not deleted.",
+		DefaultStatus: http.StatusNoContent,
+		Tags:          []string{"users"},
+	}
+}
+
+func (s *Service) DeleteUser(ctx context.Context, req *dto.DeleteUserRequest) (*dto.DeleteUserResponse, error) {
+	if err := s.checkIfMatch(apperr.OpDeleteUser, req.IfMatch, etag(stubUserUpdatedAt)); err != nil {
+		return nil, err
+	}
+
+	return &dto.DeleteUserResponse{}, nil
+}
+
+func ListUsersDocs() huma.Operation {
+	return huma.Operation{
+		OperationID:   apperr.OpListUsers,
+		Summary:       "List users",
+		Description:   "List users page by page with the given limit and offset",
+		DefaultStatus: http.StatusOK,
+		Tags:          []string{"users"},
+	}
+}
+
+func (s *Service) ListUsers(ctx context.Context, req *dto.ListUsersRequest) (*dto.ListUsersResponse, error) {
+	now := time.Now()
+	return &dto.ListUsersResponse{
+		Body: dto.ListUsersResponseBody{
+			Items: []dto.CreateUserResponseBody{
+				{
+					ID:        "123e4567-e89b-12d3-a456-426614174000",
+					Name:      "John Doe",
+					Email:     "john.doe@example.com",
+					CreatedAt: now,
+					UpdatedAt: now,
+				},
+			},
+			Limit:  req.Limit,
+			Offset: req.Offset,
 		},
 	}, nil
 }
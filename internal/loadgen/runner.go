@@ -0,0 +1,294 @@
+package loadgen
+
+import (
+	"bytes"
+	"context"
+	cryptorand "crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/pkg/correlationid"
+)
+
+var tracer = otel.Tracer("internal/loadgen")
+
+// propagator injects the traceparent header into every request.
+var propagator = propagation.TraceContext{}
+
+// Runner sends the requests of a scenario.
+// Use [NewRunner] to create a new instance of Runner.
+type Runner struct {
+	scenario Scenario
+	client   *http.Client
+	logger   *slog.Logger
+
+	// ops holds every operation once per unit of weight, to pick them by weight.
+	ops []string
+
+	mu      sync.Mutex
+	summary *Summary
+	userIDs []string
+}
+
+// NewRunner initializes a Runner for scenario, sending requests with client.
+func NewRunner(scenario Scenario, client *http.Client, logger *slog.Logger) *Runner {
+	var ops []string
+	for _, op := range operations {
+		for range scenario.Mix[op] {
+			ops = append(ops, op)
+		}
+	}
+
+	return &Runner{
+		scenario: scenario,
+		client:   client,
+		logger:   logger,
+		ops:      ops,
+		summary:  newSummary(),
+	}
+}
+
+// Run sends requests at the rate of the scenario until its duration has passed or ctx is done,
+// waits for the requests in flight and returns the summary of the responses.
+func (r *Runner) Run(ctx context.Context) *Summary {
+	r.logger.InfoContext(ctx, "load generation started",
+		slog.String("scenario", r.scenario.Name),
+		slog.String("target_url", r.scenario.TargetURL),
+		slog.Float64("rps", r.scenario.RPS),
+		slog.Duration("duration", r.scenario.Duration),
+	)
+
+	var (
+		wg       sync.WaitGroup
+		inflight = make(chan struct{}, r.scenario.Concurrency)
+		start    = time.Now()
+		timer    = time.NewTimer(0)
+	)
+	defer timer.Stop()
+
+	for n := 0; ; n++ {
+		offset := r.scenario.sendOffset(n)
+		if offset >= r.scenario.Duration {
+			break
+		}
+
+		timer.Reset(time.Until(start.Add(offset)))
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return r.finish(ctx, time.Since(start))
+		case <-timer.C:
+		}
+
+		select {
+		case inflight <- struct{}{}:
+			wg.Go(func() {
+				defer func() { <-inflight }()
+				r.send(ctx)
+			})
+		default:
+			r.mu.Lock()
+			r.summary.Skipped++
+			r.mu.Unlock()
+		}
+	}
+
+	wg.Wait()
+	return r.finish(ctx, time.Since(start))
+}
+
+func (r *Runner) finish(ctx context.Context, elapsed time.Duration) *Summary {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.summary.Elapsed = elapsed
+	r.logger.InfoContext(ctx, "load generation finished",
+		slog.String("scenario", r.scenario.Name),
+		slog.Int("requests", r.summary.Requests),
+		slog.Int("skipped", r.summary.Skipped),
+		slog.Duration("elapsed", elapsed),
+	)
+
+	return r.summary
+}
+
+// send sends a single request of a randomly picked operation and records its result.
+func (r *Runner) send(ctx context.Context) {
+	op := r.ops[rand.IntN(len(r.ops))] //nolint:gosec // Traffic shaping, not used for security.
+
+	ctx, span := tracer.Start(ctx, "loadgen."+op, trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+	// Without a configured tracer provider the span is not recording and has no IDs,
+	// but the API should still see a trace context to continue.
+	if !span.SpanContext().IsValid() {
+		ctx = trace.ContextWithSpanContext(ctx, newSpanContext())
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.scenario.Timeout)
+	defer cancel()
+
+	req, err := r.newRequest(ctx, op)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error creating request", slog.String("operation", op), slog.Any("error", err))
+		return
+	}
+	correlationID := correlationid.New()
+	req.Header.Set(correlationid.Header, correlationID)
+	propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	span.SetAttributes(
+		semconv.HTTPRequestMethodKey.String(req.Method),
+		semconv.URLFullKey.String(req.URL.String()),
+		attribute.String("correlation_id", correlationID),
+	)
+
+	start := time.Now()
+	resp, err := r.client.Do(req)
+	latency := time.Since(start)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		r.record(op, 0, latency)
+		r.logger.DebugContext(ctx, "request failed", slog.String("operation", op), slog.Any("error", err))
+		return
+	}
+	defer resp.Body.Close()
+
+	span.SetAttributes(semconv.HTTPResponseStatusCodeKey.Int(resp.StatusCode))
+	if resp.StatusCode >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+	}
+
+	if op == OpCreate && resp.StatusCode == http.StatusCreated {
+		r.rememberUser(resp.Body)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	r.record(op, resp.StatusCode, latency)
+}
+
+// newRequest builds the request of op.
+func (r *Runner) newRequest(ctx context.Context, op string) (*http.Request, error) {
+	base := strings.TrimSuffix(r.scenario.TargetURL, "/") + "/api/v1/users"
+
+	switch op {
+	case OpCreate:
+		body, err := json.Marshal(r.newUser())
+		if err != nil {
+			return nil, fmt.Errorf("marshal user: %w", err)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, base, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	case OpGet:
+		return http.NewRequestWithContext(ctx, http.MethodGet, base+"/"+r.userID(), nil)
+	case OpList:
+		url := fmt.Sprintf("%s?limit=%d&offset=%d", base, 20, rand.IntN(5)*20) //nolint:gosec // Traffic shaping, not used for security.
+		return http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	default:
+		return nil, fmt.Errorf("unknown operation %q", op)
+	}
+}
+
+type createUserRequest struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// newUser returns the payload of a create request, invalid for InvalidPercent of the requests.
+func (r *Runner) newUser() createUserRequest {
+	n := rand.IntN(1_000_000) //nolint:gosec // Traffic shaping, not used for security.
+	user := createUserRequest{
+		Name:     fmt.Sprintf("Load Test %d", n),
+		Email:    fmt.Sprintf("loadtest.%d@example.com", n),
+		Password: fmt.Sprintf("password-%d", n),
+	}
+
+	if rand.Float64()*100 < r.scenario.InvalidPercent { //nolint:gosec // Traffic shaping, not used for security.
+		switch rand.IntN(3) { //nolint:gosec // Traffic shaping, not used for security.
+		case 0:
+			user.Email = "not-an-email"
+		case 1:
+			user.Password = "short"
+		default:
+			user.Name = ""
+		}
+	}
+
+	return user
+}
+
+// rememberUser keeps the ID of a created user for later get requests.
+func (r *Runner) rememberUser(body io.Reader) {
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(body).Decode(&created); err != nil || created.ID == "" {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Keep a bounded sample of IDs.
+	const maxUserIDs = 1000
+	if len(r.userIDs) < maxUserIDs {
+		r.userIDs = append(r.userIDs, created.ID)
+	} else {
+		r.userIDs[rand.IntN(maxUserIDs)] = created.ID //nolint:gosec // Traffic shaping, not used for security.
+	}
+}
+
+// userID returns the ID of a created user, or a random one if none was created yet.
+func (r *Runner) userID() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.userIDs) == 0 {
+		return uuid.NewString()
+	}
+
+	return r.userIDs[rand.IntN(len(r.userIDs))] //nolint:gosec // Traffic shaping, not used for security.
+}
+
+func (r *Runner) record(op string, status int, latency time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.summary.record(op, status, latency)
+}
+
+// newSpanContext returns a sampled span context with random IDs.
+func newSpanContext() trace.SpanContext {
+	var (
+		traceID trace.TraceID
+		spanID  trace.SpanID
+	)
+	_, _ = cryptorand.Read(traceID[:])
+	_, _ = cryptorand.Read(spanID[:])
+
+	return trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	})
+}
@@ -0,0 +1,176 @@
+package loadgen
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/pkg/correlationid"
+)
+
+// fakeAPI is a stand-in for the users API recording the requests it receives.
+type fakeAPI struct {
+	mu       sync.Mutex
+	requests map[string]int
+	// missingHeaders counts the requests without a traceparent or correlation ID.
+	missingHeaders int
+	nextID         atomic.Int64
+}
+
+func newFakeAPI() *fakeAPI {
+	return &fakeAPI{requests: make(map[string]int)}
+}
+
+func (a *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	if r.Header.Get("traceparent") == "" || r.Header.Get(correlationid.Header) == "" {
+		a.missingHeaders++
+	}
+	a.mu.Unlock()
+
+	var op string
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v1/users":
+		op = OpCreate
+		var user createUserRequest
+		if err := json.NewDecoder(r.Body).Decode(&user); err != nil ||
+			user.Name == "" || !strings.Contains(user.Email, "@") || len(user.Password) < 8 {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			break
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]string{"id": strconv.FormatInt(a.nextID.Add(1), 10)})
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/api/v1/users/"):
+		op = OpGet
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v1/users" && r.URL.Query().Get("limit") == "20":
+		op = OpList
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	a.mu.Lock()
+	a.requests[op]++
+	a.mu.Unlock()
+}
+
+func testScenario(targetURL string) Scenario {
+	return Scenario{
+		Name:        "test",
+		TargetURL:   targetURL,
+		RPS:         200,
+		Duration:    250 * time.Millisecond,
+		Concurrency: 50,
+		Timeout:     time.Second,
+		Mix:         map[string]int{OpCreate: 1, OpGet: 1, OpList: 1},
+	}
+}
+
+func TestRunnerRun(t *testing.T) {
+	api := newFakeAPI()
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	summary := NewRunner(testScenario(srv.URL), srv.Client(), slog.New(slog.DiscardHandler)).Run(context.Background())
+
+	// The n-th request is due at n/RPS, so 50 requests are due within 250ms.
+	if summary.Requests != 50 {
+		t.Errorf("Requests = %d, want 50", summary.Requests)
+	}
+	if summary.Skipped != 0 || summary.Errors != 0 {
+		t.Errorf("Skipped = %d, Errors = %d, want 0", summary.Skipped, summary.Errors)
+	}
+	if got := summary.Statuses[http.StatusOK] + summary.Statuses[http.StatusCreated]; got != summary.Requests {
+		t.Errorf("successful responses = %d, want %d, statuses %v", got, summary.Requests, summary.Statuses)
+	}
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	total := 0
+	for _, op := range operations {
+		if api.requests[op] == 0 {
+			t.Errorf("no %s request received", op)
+		}
+		total += api.requests[op]
+	}
+	if total != summary.Requests {
+		t.Errorf("API received %d requests, summary has %d", total, summary.Requests)
+	}
+	if api.missingHeaders != 0 {
+		t.Errorf("%d requests without traceparent or correlation ID", api.missingHeaders)
+	}
+}
+
+func TestRunnerInvalidPayloads(t *testing.T) {
+	api := newFakeAPI()
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	scenario := testScenario(srv.URL)
+	scenario.Mix = map[string]int{OpCreate: 1}
+	scenario.InvalidPercent = 100
+
+	summary := NewRunner(scenario, srv.Client(), slog.New(slog.DiscardHandler)).Run(context.Background())
+
+	if summary.Statuses[http.StatusUnprocessableEntity] != summary.Requests {
+		t.Errorf("statuses = %v, want only 422 for %d requests", summary.Statuses, summary.Requests)
+	}
+}
+
+func TestRunnerSkipsAboveConcurrency(t *testing.T) {
+	// The API does not answer before the end of the test, so the only request
+	// in flight times out.
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	scenario := testScenario(srv.URL)
+	scenario.RPS = 100
+	scenario.Duration = 100 * time.Millisecond
+	scenario.Concurrency = 1
+	scenario.Timeout = 300 * time.Millisecond
+
+	summary := NewRunner(scenario, srv.Client(), slog.New(slog.DiscardHandler)).Run(context.Background())
+
+	if summary.Requests != 1 || summary.Errors != 1 {
+		t.Errorf("Requests = %d, Errors = %d, want 1 failed request", summary.Requests, summary.Errors)
+	}
+	if summary.Skipped != 9 {
+		t.Errorf("Skipped = %d, want 9", summary.Skipped)
+	}
+}
+
+func TestRunnerStopsWithContext(t *testing.T) {
+	srv := httptest.NewServer(newFakeAPI())
+	defer srv.Close()
+
+	scenario := testScenario(srv.URL)
+	scenario.Duration = time.Minute
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	summary := NewRunner(scenario, srv.Client(), slog.New(slog.DiscardHandler)).Run(ctx)
+
+	if summary.Elapsed >= time.Second {
+		t.Errorf("Elapsed = %s, want the run to stop with the context", summary.Elapsed)
+	}
+	if summary.Requests == 0 {
+		t.Error("Requests = 0, want the requests sent before the context was done")
+	}
+}
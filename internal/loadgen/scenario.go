@@ -0,0 +1,106 @@
+// Package loadgen generates HTTP traffic against the API to drive the observability stack.
+package loadgen
+
+import (
+	"errors"
+	"maps"
+	"math"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/pkg/config"
+)
+
+// Operations of a scenario.
+const (
+	OpCreate = "create"
+	OpGet    = "get"
+	OpList   = "list"
+)
+
+var operations = []string{OpCreate, OpGet, OpList}
+
+// Scenario describes the traffic to generate.
+type Scenario struct {
+	Name string `yaml:"name"`
+	// TargetURL is the base URL of the API, e.g. http://localhost:8000.
+	TargetURL string `yaml:"target_url"`
+	// RPS is the target number of requests per second, reached after RampUp.
+	RPS float64 `yaml:"rps"`
+	// RampUp is the time over which the rate grows linearly from 0 to RPS.
+	RampUp   time.Duration `yaml:"ramp_up"`
+	Duration time.Duration `yaml:"duration"`
+	// Concurrency is the maximum number of requests in flight. Requests that would exceed it
+	// are skipped and counted, so a slow API does not slow down the schedule.
+	Concurrency int           `yaml:"concurrency"`
+	Timeout     time.Duration `yaml:"timeout"`
+	// InvalidPercent is the percentage of create requests with an invalid payload,
+	// which the API rejects with 422 Unprocessable Entity.
+	InvalidPercent float64 `yaml:"invalid_percent"`
+	// Mix maps the operations create, get and list to their relative weight.
+	Mix map[string]int `yaml:"mix"`
+}
+
+func (s *Scenario) Validate() error {
+	var errs []error
+
+	if u, err := url.Parse(s.TargetURL); err != nil {
+		errs = append(errs, config.Field("target_url", err))
+	} else if u.Scheme != "http" && u.Scheme != "https" {
+		errs = append(errs, config.Fieldf("target_url", "must be an http or https URL"))
+	}
+	if s.RPS <= 0 {
+		errs = append(errs, config.Fieldf("rps", "must be greater than 0"))
+	}
+	if s.RampUp < 0 {
+		errs = append(errs, config.Fieldf("ramp_up", "must not be negative"))
+	}
+	if s.Duration <= 0 {
+		errs = append(errs, config.Fieldf("duration", "must be greater than 0"))
+	}
+	if s.Concurrency <= 0 {
+		errs = append(errs, config.Fieldf("concurrency", "must be greater than 0"))
+	}
+	if s.Timeout <= 0 {
+		errs = append(errs, config.Fieldf("timeout", "must be greater than 0"))
+	}
+	if s.InvalidPercent < 0 || s.InvalidPercent > 100 {
+		errs = append(errs, config.Fieldf("invalid_percent", "must be between 0 and 100"))
+	}
+
+	total := 0
+	for _, op := range slices.Sorted(maps.Keys(s.Mix)) {
+		weight := s.Mix[op]
+		if !slices.Contains(operations, op) {
+			errs = append(errs, config.Fieldf("mix."+op, "is not an operation, must be one of %s", strings.Join(operations, ", ")))
+		}
+		if weight < 0 {
+			errs = append(errs, config.Fieldf("mix."+op, "must not be negative"))
+		}
+		total += weight
+	}
+	if total <= 0 {
+		errs = append(errs, config.Fieldf("mix", "must have an operation with a weight greater than 0"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// sendOffset returns when the n-th request (starting at 0) is due, relative to the start.
+// The rate grows linearly from 0 to RPS during the ramp-up, so the number of requests
+// due after t is RPS*t²/(2*RampUp) until the ramp-up ends and grows by RPS per second after.
+func (s *Scenario) sendOffset(n int) time.Duration {
+	rampUp := s.RampUp.Seconds()
+	rampUpRequests := s.RPS * rampUp / 2
+
+	var offset float64
+	if float64(n) < rampUpRequests {
+		offset = math.Sqrt(2 * float64(n) * rampUp / s.RPS)
+	} else {
+		offset = rampUp + (float64(n)-rampUpRequests)/s.RPS
+	}
+
+	return time.Duration(offset * float64(time.Second))
+}
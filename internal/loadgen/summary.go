@@ -0,0 +1,120 @@
+package loadgen
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// Summary holds the results of a [Runner.Run].
+type Summary struct {
+	// Requests is the number of requests sent, including the ones that failed.
+	Requests int
+	// Skipped is the number of requests not sent because Concurrency requests were in flight.
+	Skipped int
+	// Errors is the number of requests that failed without a response.
+	Errors  int
+	Elapsed time.Duration
+
+	// Statuses maps a status code to the number of responses with it.
+	Statuses map[int]int
+	// latencies maps an operation to the latencies of its requests.
+	latencies map[string][]time.Duration
+}
+
+func newSummary() *Summary {
+	return &Summary{
+		Statuses:  make(map[int]int),
+		latencies: make(map[string][]time.Duration),
+	}
+}
+
+// record adds a request to the summary. A status of 0 means the request failed without a response.
+func (s *Summary) record(op string, status int, latency time.Duration) {
+	s.Requests++
+	if status == 0 {
+		s.Errors++
+	} else {
+		s.Statuses[status]++
+	}
+	s.latencies[op] = append(s.latencies[op], latency)
+}
+
+// Write writes the summary as tables of the status codes and the latencies per operation.
+func (s *Summary) Write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	rps := 0.0
+	if s.Elapsed > 0 {
+		rps = float64(s.Requests) / s.Elapsed.Seconds()
+	}
+	fmt.Fprintf(tw, "requests\t%d\n", s.Requests)
+	fmt.Fprintf(tw, "skipped\t%d\n", s.Skipped)
+	fmt.Fprintf(tw, "errors\t%d\n", s.Errors)
+	fmt.Fprintf(tw, "elapsed\t%s\n", s.Elapsed.Round(time.Millisecond))
+	fmt.Fprintf(tw, "rps\t%.1f\n", rps)
+
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "STATUS\tCOUNT\tPERCENT")
+	for _, status := range slices.Sorted(maps.Keys(s.Statuses)) {
+		count := s.Statuses[status]
+		fmt.Fprintf(tw, "%d\t%d\t%.1f%%\n", status, count, float64(count)*100/float64(s.Requests))
+	}
+	if s.Errors > 0 {
+		fmt.Fprintf(tw, "error\t%d\t%.1f%%\n", s.Errors, float64(s.Errors)*100/float64(s.Requests))
+	}
+
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "OPERATION\tCOUNT\tMEAN\tP50\tP90\tP99\tMAX")
+	var all []time.Duration
+	for _, op := range operations {
+		if latencies, ok := s.latencies[op]; ok {
+			writeLatencies(tw, op, latencies)
+			all = append(all, latencies...)
+		}
+	}
+	if len(all) > 0 {
+		writeLatencies(tw, "all", all)
+	}
+
+	return tw.Flush()
+}
+
+func writeLatencies(w io.Writer, name string, latencies []time.Duration) {
+	latencies = slices.Clone(latencies)
+	slices.Sort(latencies)
+
+	var total time.Duration
+	for _, l := range latencies {
+		total += l
+	}
+	mean := total / time.Duration(len(latencies))
+
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+		name,
+		strconv.Itoa(len(latencies)),
+		formatLatency(mean),
+		formatLatency(percentile(latencies, 50)),
+		formatLatency(percentile(latencies, 90)),
+		formatLatency(percentile(latencies, 99)),
+		formatLatency(latencies[len(latencies)-1]),
+	)
+}
+
+// percentile returns the p-th percentile of sorted latencies using the nearest-rank method.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(p / 100 * float64(len(sorted)))
+	if float64(rank) < p/100*float64(len(sorted)) {
+		rank++
+	}
+
+	return sorted[max(rank, 1)-1]
+}
+
+func formatLatency(d time.Duration) string {
+	return d.Round(10 * time.Microsecond).String()
+}
@@ -0,0 +1,159 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lmittmann/tint"
+)
+
+// Naming is the naming convention of the standard fields of the records.
+type Naming string
+
+const (
+	// NamingDefault keeps the names of log/slog: time, level, msg and source.
+	NamingDefault Naming = "default"
+	// NamingECS follows the Elastic Common Schema, e.g. @timestamp, log.level and message.
+	NamingECS Naming = "ecs"
+	// NamingOTel follows the OpenTelemetry log data model, e.g. timestamp, severity_text and body.
+	NamingOTel Naming = "otel"
+)
+
+func (n Naming) validate() error {
+	switch n {
+	case "", NamingDefault, NamingECS, NamingOTel:
+		return nil
+	default:
+		return fmt.Errorf("must be one of %s, %s or %s", NamingDefault, NamingECS, NamingOTel)
+	}
+}
+
+// fieldNames maps the standard fields of a naming convention to their names.
+var fieldNames = map[Naming]map[string]string{
+	NamingECS: {
+		slog.TimeKey:     "@timestamp",
+		slog.LevelKey:    "log.level",
+		slog.MessageKey:  "message",
+		slog.SourceKey:   "log.origin",
+		"error":          "error.message",
+		"trace_id":       "trace.id",
+		"span_id":        "span.id",
+		"correlation_id": "labels.correlation_id",
+	},
+	NamingOTel: {
+		slog.TimeKey:    "timestamp",
+		slog.LevelKey:   "severity_text",
+		slog.MessageKey: "body",
+		slog.SourceKey:  "code",
+		"error":         "exception.message",
+	},
+}
+
+// victoriaLogsFieldNames maps the standard fields to the fields VictoriaLogs reads
+// the message and time of a record from.
+var victoriaLogsFieldNames = map[string]string{
+	slog.TimeKey:    "_time",
+	slog.MessageKey: "_msg",
+}
+
+type formatOptions struct {
+	level     slog.Level
+	addSource bool
+	noColor   bool
+	naming    Naming
+	rename    map[string]string
+}
+
+// newFormatHandler returns the handler writing records to w in format.
+func newFormatHandler(w io.Writer, format Format, opts formatOptions) slog.Handler {
+	names := fieldNames[opts.naming]
+	if format == FormatVictoriaLogs {
+		// VictoriaLogs requires its own names for the message and the time.
+		names = maps.Clone(names)
+		if names == nil {
+			names = make(map[string]string, len(victoriaLogsFieldNames))
+		}
+		maps.Copy(names, victoriaLogsFieldNames)
+	}
+	replaceAttr := renameAttr(names, opts.rename)
+
+	switch format {
+	case FormatJSON, FormatVictoriaLogs:
+		return slog.NewJSONHandler(w, &slog.HandlerOptions{
+			Level:       opts.level,
+			AddSource:   opts.addSource,
+			ReplaceAttr: replaceAttr,
+		})
+	case FormatLogfmt:
+		return slog.NewTextHandler(w, &slog.HandlerOptions{
+			Level:       opts.level,
+			AddSource:   opts.addSource,
+			ReplaceAttr: replaceAttr,
+		})
+	default:
+		return tint.NewHandler(w, &tint.Options{
+			Level:      opts.level,
+			AddSource:  opts.addSource,
+			TimeFormat: time.RFC3339,
+			NoColor:    opts.noColor,
+			ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+				// tint writes the time, level and message without their keys,
+				// so only the other fields are renamed.
+				if replaceAttr != nil && (len(groups) > 0 || !isBuiltinKey(a.Key)) {
+					a = replaceAttr(groups, a)
+				}
+				if a.Value.Kind() == slog.KindAny {
+					if _, ok := a.Value.Any().(error); ok {
+						return tint.Attr(9, a)
+					}
+				}
+				return a
+			},
+		})
+	}
+}
+
+// renameAttr returns a ReplaceAttr function renaming the top-level standard fields
+// with names, then any field with rename, or nil if nothing is renamed.
+func renameAttr(names, rename map[string]string) func(groups []string, a slog.Attr) slog.Attr {
+	if len(names) == 0 && len(rename) == 0 {
+		return nil
+	}
+
+	return func(groups []string, a slog.Attr) slog.Attr {
+		if len(groups) == 0 {
+			if name, ok := names[a.Key]; ok {
+				a.Key = name
+			}
+		}
+
+		path := a.Key
+		if len(groups) > 0 {
+			path = strings.Join(groups, ".") + "." + a.Key
+		}
+		if name, ok := rename[path]; ok {
+			a.Key = name
+		}
+
+		return a
+	}
+}
+
+func isBuiltinKey(key string) bool {
+	return key == slog.TimeKey || key == slog.LevelKey || key == slog.MessageKey
+}
+
+// streamAttrs returns the stream fields as attributes in a stable order.
+func streamAttrs(fields map[string]string) []slog.Attr {
+	attrs := make([]slog.Attr, 0, len(fields))
+	for _, key := range slices.Sorted(maps.Keys(fields)) {
+		attrs = append(attrs, slog.String(key, fields[key]))
+	}
+
+	return attrs
+}
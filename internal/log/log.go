@@ -1,37 +1,69 @@
 package log
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
-	"os"
 	"strings"
-	"time"
 
-	"github.com/lmittmann/tint"
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/pkg/config"
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/pkg/redact"
 )
 
 // Config represents the logging configuration.
 type Config struct {
+	// Format and Level apply to the outputs that do not set their own.
 	Format    Format     `yaml:"format"`
 	Level     slog.Level `yaml:"level"`
 	AddSource bool       `yaml:"add_source"`
+	// Outputs are the outputs every record is written to. No outputs write to stdout.
+	Outputs []OutputConfig `yaml:"outputs"`
+	// Redact holds the rules to redact secrets and personal data from the logs.
+	// The same rules are applied to the span attributes.
+	Redact redact.Config `yaml:"redact"`
+	// Sampling limits the number of records logged with the same level and message.
+	Sampling SamplingConfig `yaml:"sampling"`
+	// Naming is the naming convention of the standard fields: default, ecs or otel.
+	Naming Naming `yaml:"naming" enum:"default,ecs,otel"`
+	// Rename maps a field, with its groups joined by dots (e.g. body.email), to a new name.
+	// Fields are renamed after the naming convention is applied.
+	Rename map[string]string `yaml:"rename"`
+	// StreamFields are added to every record. VictoriaLogs uses them to group the
+	// records into streams, see https://docs.victoriametrics.com/victorialogs/keyconcepts/#stream-fields.
+	StreamFields map[string]string `yaml:"stream_fields"`
 }
 
 func (l *Config) Validate() error {
-	return nil
+	var errs []error
+	if err := l.Naming.validate(); err != nil {
+		errs = append(errs, config.Field("naming", err))
+	}
+	for i := range l.Outputs {
+		if err := l.Outputs[i].Validate(); err != nil {
+			errs = append(errs, config.Field(fmt.Sprintf("outputs.%d", i), err))
+		}
+	}
+
+	return errors.Join(errs...)
 }
 
-// Format represents the logging format (JSON or Text).
+// Format represents the logging format.
 type Format uint8
 
 const (
 	FormatJSON Format = iota
+	// FormatText is colored text for humans.
 	FormatText
+	// FormatVictoriaLogs is JSON with the message in _msg and the time in _time,
+	// ingestible by VictoriaLogs without remapping.
+	FormatVictoriaLogs
+	// FormatLogfmt is key=value pairs.
+	FormatLogfmt
 )
 
 // String implements flag.Value.
 func (f Format) String() string {
-	return []string{"JSON", "TEXT"}[f]
+	return []string{"JSON", "TEXT", "VICTORIALOGS", "LOGFMT"}[f]
 }
 
 // Set implements flag.Value.
@@ -46,6 +78,10 @@ func (f *Format) UnmarshalText(text []byte) error {
 		*f = FormatJSON
 	case "TEXT":
 		*f = FormatText
+	case "VICTORIALOGS":
+		*f = FormatVictoriaLogs
+	case "LOGFMT":
+		*f = FormatLogfmt
 	default:
 		return fmt.Errorf("unknown log format: %s", text)
 	}
@@ -57,33 +93,87 @@ func (f Format) MarshalText() ([]byte, error) {
 	return []byte(f.String()), nil
 }
 
+// CleanupFunc closes the outputs of a logger.
+type CleanupFunc func() error
+
 // NewLogger creates a new slog.Logger with the given configuration.
-func NewLogger(cfg Config) (*slog.Logger, error) {
-	var handler slog.Handler
-
-	if cfg.Format == FormatJSON {
-		handler = slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-			Level:     cfg.Level,
-			AddSource: cfg.AddSource,
-		})
-	} else {
-		handler = tint.NewHandler(os.Stdout, &tint.Options{
-			Level:      cfg.Level,
-			AddSource:  cfg.AddSource,
-			TimeFormat: time.RFC3339,
-			ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
-				if a.Value.Kind() == slog.KindAny {
-					if _, ok := a.Value.Any().(error); ok {
-						return tint.Attr(9, a)
-					}
-				}
-				return a
-			},
-		})
+// The configuration of the returned logger can be changed later with [Reload].
+// The returned cleanup function closes its outputs.
+func NewLogger(cfg Config) (*slog.Logger, CleanupFunc, error) {
+	handler, closeOutputs, err := newHandler(cfg)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	log := slog.New(newTraceHandler(handler))
+	sh := newSwapHandler(handler, closeOutputs)
+	log := slog.New(sh)
 	slog.SetDefault(log)
 
-	return log, nil
+	return log, sh.close, nil
+}
+
+// newHandler builds the handler chain described by cfg.
+// The returned function closes the outputs of the chain.
+func newHandler(cfg Config) (slog.Handler, func() error, error) {
+	outputs := cfg.Outputs
+	if len(outputs) == 0 {
+		outputs = []OutputConfig{{Type: OutputStdout}}
+	}
+
+	handlers := make([]slog.Handler, 0, len(outputs))
+	writers := make([]*outputWriter, 0, len(outputs))
+	for _, output := range outputs {
+		w := newOutputWriter(output, streamFieldNames(cfg.StreamFields))
+		writers = append(writers, w)
+
+		level, format := cfg.Level, cfg.Format
+		if output.Level != nil {
+			level = *output.Level
+		}
+		if output.Format != nil {
+			format = *output.Format
+		}
+		if output.Type == OutputVictoriaLogs {
+			format = FormatVictoriaLogs
+		}
+		// Colors would end up as escape codes in files.
+		noColor := output.Type == OutputFile || output.Type == OutputVictoriaLogs
+		handlers = append(handlers, newFormatHandler(w, format, formatOptions{
+			level:     level,
+			addSource: cfg.AddSource,
+			noColor:   noColor,
+			naming:    cfg.Naming,
+			rename:    cfg.Rename,
+		}))
+	}
+
+	closeOutputs := func() error {
+		var errs []error
+		for _, w := range writers {
+			errs = append(errs, w.Close())
+		}
+		return errors.Join(errs...)
+	}
+
+	var handler slog.Handler = slog.NewMultiHandler(handlers...)
+	if len(handlers) == 1 {
+		handler = handlers[0]
+	}
+	if len(cfg.StreamFields) > 0 {
+		handler = handler.WithAttrs(streamAttrs(cfg.StreamFields))
+	}
+
+	handler = newRedactHandler(newTraceHandler(handler), redact.New(cfg.Redact))
+	if cfg.Sampling.Enabled {
+		sh := newSamplingHandler(handler, cfg.Sampling)
+		handler = sh
+		// The last summary is logged before the outputs are closed.
+		closeWriters := closeOutputs
+		closeOutputs = func() error {
+			sh.close()
+			return closeWriters()
+		}
+	}
+
+	return handler, closeOutputs, nil
 }
@@ -0,0 +1,179 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gopkg.in/natefinch/lumberjack.v2"
+
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/pkg/config"
+)
+
+var writeErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "log_write_errors_total",
+	Help: "Total number of log records that could not be written to an output",
+}, []string{"output"})
+
+// Output types.
+const (
+	OutputStdout = "stdout"
+	OutputStderr = "stderr"
+	OutputFile   = "file"
+	// OutputVictoriaLogs ships the records to VictoriaLogs in the victorialogs format.
+	OutputVictoriaLogs = "victorialogs"
+)
+
+// OutputConfig configures an output the records are written to.
+type OutputConfig struct {
+	// Type is stdout, stderr, file or victorialogs.
+	Type string `yaml:"type" enum:"stdout,stderr,file,victorialogs"`
+	// Level and Format default to the level and format of the logger.
+	// The format of victorialogs outputs is always victorialogs.
+	Level        *slog.Level        `yaml:"level"`
+	Format       *Format            `yaml:"format"`
+	File         FileConfig         `yaml:"file"`
+	VictoriaLogs VictoriaLogsConfig `yaml:"victorialogs"`
+}
+
+// FileConfig configures an output file and its rotation.
+type FileConfig struct {
+	Path string `yaml:"path"`
+	// MaxSizeMB is the size in megabytes at which the file is rotated.
+	MaxSizeMB int `yaml:"max_size_mb"`
+	// MaxAgeDays is the number of days rotated files are kept. 0 keeps them regardless of age.
+	MaxAgeDays int `yaml:"max_age_days"`
+	// MaxBackups is the number of rotated files kept. 0 keeps them all.
+	MaxBackups int `yaml:"max_backups"`
+	// Compress compresses the rotated files with gzip.
+	Compress bool `yaml:"compress"`
+}
+
+func (o *OutputConfig) Validate() error {
+	switch o.Type {
+	case OutputStdout, OutputStderr:
+		return nil
+	case OutputFile:
+	case OutputVictoriaLogs:
+		if err := o.VictoriaLogs.Validate(); err != nil {
+			return config.Field("victorialogs", err)
+		}
+		return nil
+	default:
+		return config.Fieldf("type", "must be one of %s, %s, %s or %s",
+			OutputStdout, OutputStderr, OutputFile, OutputVictoriaLogs)
+	}
+
+	var errs []error
+	if o.File.Path == "" {
+		errs = append(errs, config.Fieldf("file.path", "is required"))
+	}
+	if o.File.MaxSizeMB < 0 {
+		errs = append(errs, config.Fieldf("file.max_size_mb", "must not be negative"))
+	}
+	if o.File.MaxAgeDays < 0 {
+		errs = append(errs, config.Fieldf("file.max_age_days", "must not be negative"))
+	}
+	if o.File.MaxBackups < 0 {
+		errs = append(errs, config.Fieldf("file.max_backups", "must not be negative"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// name returns the name of the output in the write errors metric.
+func (o *OutputConfig) name() string {
+	switch o.Type {
+	case OutputFile:
+		return o.File.Path
+	case OutputVictoriaLogs:
+		return o.VictoriaLogs.URL
+	default:
+		return o.Type
+	}
+}
+
+// outputWriter writes to an output. When a write fails, e.g. because the disk is full,
+// the record is written to stderr instead and counted as a write error,
+// so a failing output does not stop the service.
+type outputWriter struct {
+	name     string
+	w        io.Writer
+	fallback io.Writer
+	// closer closes w, or is nil if w is stdout or stderr.
+	closer io.Closer
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// newOutputWriter opens the output of cfg. streamFields are the names of the
+// fields VictoriaLogs groups the records into streams by.
+func newOutputWriter(cfg OutputConfig, streamFields []string) *outputWriter {
+	ow := &outputWriter{name: cfg.name(), fallback: os.Stderr}
+
+	switch cfg.Type {
+	case OutputStdout:
+		ow.w = os.Stdout
+	case OutputStderr:
+		ow.w = os.Stderr
+		// There is nowhere left to write to.
+		ow.fallback = io.Discard
+	case OutputFile:
+		file := &lumberjack.Logger{
+			Filename:   cfg.File.Path,
+			MaxSize:    cfg.File.MaxSizeMB,
+			MaxAge:     cfg.File.MaxAgeDays,
+			MaxBackups: cfg.File.MaxBackups,
+			Compress:   cfg.File.Compress,
+		}
+		ow.w, ow.closer = file, file
+	case OutputVictoriaLogs:
+		shipper := newShipper(cfg.VictoriaLogs, streamFields)
+		ow.w, ow.closer = shipper, shipper
+	}
+
+	return ow
+}
+
+func (ow *outputWriter) Write(p []byte) (int, error) {
+	ow.mu.Lock()
+	defer ow.mu.Unlock()
+
+	// Records of loggers still holding the previous handlers after a reload
+	// are not written to the closed output, e.g. a file would be reopened.
+	if ow.closed {
+		return ow.fallback.Write(p)
+	}
+
+	n, err := ow.w.Write(p)
+	if err != nil {
+		writeErrorsTotal.WithLabelValues(ow.name).Inc()
+		return ow.fallback.Write(p)
+	}
+
+	return n, nil
+}
+
+// Close closes the output, sending the records queued for VictoriaLogs.
+// Stdout and stderr are left open.
+func (ow *outputWriter) Close() error {
+	if ow.closer == nil {
+		return nil
+	}
+
+	ow.mu.Lock()
+	defer ow.mu.Unlock()
+
+	ow.closed = true
+	if err := ow.closer.Close(); err != nil {
+		return fmt.Errorf("close %s: %w", ow.name, err)
+	}
+
+	return nil
+}
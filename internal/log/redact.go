@@ -0,0 +1,93 @@
+package log
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/pkg/redact"
+)
+
+var _ slog.Handler = (*redactHandler)(nil)
+
+// redactHandler redacts the attributes of records before they are written.
+// Values implementing [slog.LogValuer] are resolved first, so the attributes they return
+// are redacted as well.
+type redactHandler struct {
+	h        slog.Handler
+	redactor *redact.Redactor
+}
+
+func newRedactHandler(h slog.Handler, redactor *redact.Redactor) redactHandler {
+	return redactHandler{h: h, redactor: redactor}
+}
+
+func (rh redactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return rh.h.Enabled(ctx, level)
+}
+
+func (rh redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	redacted := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		redacted.AddAttrs(rh.attr(a))
+		return true
+	})
+
+	return rh.h.Handle(ctx, redacted)
+}
+
+func (rh redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = rh.attr(a)
+	}
+
+	return newRedactHandler(rh.h.WithAttrs(redacted), rh.redactor)
+}
+
+func (rh redactHandler) WithGroup(name string) slog.Handler {
+	return newRedactHandler(rh.h.WithGroup(name), rh.redactor)
+}
+
+func (rh redactHandler) attr(a slog.Attr) slog.Attr {
+	// The value of a sensitive key is replaced as a whole, groups included.
+	if rh.redactor.Sensitive(a.Key) {
+		a.Value = slog.StringValue(redact.Placeholder)
+		return a
+	}
+	a.Value = a.Value.Resolve()
+
+	switch a.Value.Kind() {
+	case slog.KindGroup:
+		group := a.Value.Group()
+		redacted := make([]slog.Attr, len(group))
+		for i, ga := range group {
+			redacted[i] = rh.attr(ga)
+		}
+		a.Value = slog.GroupValue(redacted...)
+	case slog.KindString:
+		a.Value = slog.StringValue(rh.redactor.String(a.Key, a.Value.String()))
+	case slog.KindAny:
+		if fields, ok := rh.redactor.Fields(a.Value.Any()); ok {
+			a.Value = fieldsValue(fields)
+		} else {
+			a.Value = slog.AnyValue(rh.redactor.Any(a.Key, a.Value.Any()))
+		}
+	}
+
+	return a
+}
+
+// fieldsValue returns the redacted fields of a struct as a group, so text handlers
+// write them as key=value pairs as well.
+func fieldsValue(fields []redact.Field) slog.Value {
+	attrs := make([]slog.Attr, len(fields))
+	for i, f := range fields {
+		if nested, ok := f.Value.([]redact.Field); ok {
+			attrs[i] = slog.Attr{Key: f.Name, Value: fieldsValue(nested)}
+		} else {
+			attrs[i] = slog.Any(f.Name, f.Value)
+		}
+	}
+
+	return slog.GroupValue(attrs...)
+}
@@ -0,0 +1,93 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/pkg/redact"
+)
+
+// secret is a LogValuer returning a secret, resolved before redaction.
+type secret string
+
+func (s secret) LogValue() slog.Value { return slog.StringValue(string(s)) }
+
+func TestRedactHandler(t *testing.T) {
+	type user struct {
+		Name     string `json:"name"`
+		Password string `json:"password" redact:"true"`
+	}
+
+	tests := []struct {
+		name string
+		attr slog.Attr
+		want any
+	}{
+		{name: "sensitive string", attr: slog.String("token", "abc"), want: redact.Placeholder},
+		{name: "sensitive number", attr: slog.Int("token", 42), want: redact.Placeholder},
+		{name: "email", attr: slog.String("email", "john@example.com"), want: "j***@example.com"},
+		{name: "other string", attr: slog.String("path", "/users"), want: "/users"},
+		{
+			name: "sensitive group",
+			attr: slog.Group("credentials", slog.String("user", "john"), slog.Int("pin", 1234)),
+			want: redact.Placeholder,
+		},
+		{
+			name: "group",
+			attr: slog.Group("request", slog.String("token", "abc"), slog.String("path", "/users")),
+			want: map[string]any{"token": redact.Placeholder, "path": "/users"},
+		},
+		{name: "sensitive LogValuer", attr: slog.Any("token", secret("abc")), want: redact.Placeholder},
+		{name: "LogValuer", attr: slog.Any("value", secret("abc")), want: "abc"},
+		{
+			name: "tagged struct",
+			attr: slog.Any("user", user{Name: "John", Password: "password123"}),
+			want: map[string]any{"name": "John", "password": redact.Placeholder},
+		},
+		{name: "sensitive struct", attr: slog.Any("token", user{Name: "John"}), want: redact.Placeholder},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			redactor := redact.New(redact.Config{Keys: []string{"token", "credentials"}, EmailKeys: []string{"email"}, Email: redact.EmailMask})
+			logger := slog.New(newRedactHandler(slog.NewJSONHandler(&buf, nil), redactor))
+
+			logger.Info("test", tt.attr)
+
+			var record map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+				t.Fatalf("decode record: %v", err)
+			}
+			got, err := json.Marshal(record[tt.attr.Key])
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := json.Marshal(tt.want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("%s = %s, want %s", tt.attr.Key, got, want)
+			}
+		})
+	}
+}
+
+func TestRedactHandlerWithAttrs(t *testing.T) {
+	var buf bytes.Buffer
+	redactor := redact.New(redact.Config{Keys: []string{"token"}, Email: redact.EmailKeep})
+	logger := slog.New(newRedactHandler(slog.NewJSONHandler(&buf, nil), redactor)).
+		With(slog.Group("token", slog.String("value", "abc")))
+
+	logger.Info("test")
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("decode record: %v", err)
+	}
+	if record["token"] != redact.Placeholder {
+		t.Errorf("token = %v, want %s", record["token"], redact.Placeholder)
+	}
+}
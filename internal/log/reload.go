@@ -0,0 +1,107 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+)
+
+var _ slog.Handler = (*swapHandler)(nil)
+
+// rootHandler boxes the handler built from a Config so it can be swapped atomically.
+type rootHandler struct {
+	h slog.Handler
+	// closeOutputs closes the outputs h writes to.
+	closeOutputs func() error
+}
+
+// swapHandler forwards records to a root handler that can be replaced at runtime.
+// Attributes and groups added through WithAttrs and WithGroup are replayed on top
+// of the new root the first time it is used.
+type swapHandler struct {
+	root *atomic.Pointer[rootHandler]
+	ops  []func(slog.Handler) slog.Handler
+
+	// cached holds the root the ops were last applied to and the result.
+	cached atomic.Pointer[cachedHandler]
+}
+
+type cachedHandler struct {
+	root *rootHandler
+	h    slog.Handler
+}
+
+func newSwapHandler(h slog.Handler, closeOutputs func() error) *swapHandler {
+	root := &atomic.Pointer[rootHandler]{}
+	root.Store(&rootHandler{h: h, closeOutputs: closeOutputs})
+	return &swapHandler{root: root}
+}
+
+// close closes the outputs of the current root handler.
+func (sh *swapHandler) close() error {
+	return sh.root.Load().closeOutputs()
+}
+
+func (sh *swapHandler) handler() slog.Handler {
+	root := sh.root.Load()
+	if c := sh.cached.Load(); c != nil && c.root == root {
+		return c.h
+	}
+
+	h := root.h
+	for _, op := range sh.ops {
+		h = op(h)
+	}
+	sh.cached.Store(&cachedHandler{root: root, h: h})
+
+	return h
+}
+
+func (sh *swapHandler) with(op func(slog.Handler) slog.Handler) *swapHandler {
+	ops := make([]func(slog.Handler) slog.Handler, len(sh.ops), len(sh.ops)+1)
+	copy(ops, sh.ops)
+	return &swapHandler{root: sh.root, ops: append(ops, op)}
+}
+
+func (sh *swapHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return sh.handler().Enabled(ctx, level)
+}
+
+func (sh *swapHandler) Handle(ctx context.Context, r slog.Record) error {
+	return sh.handler().Handle(ctx, r)
+}
+
+func (sh *swapHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return sh.with(func(h slog.Handler) slog.Handler {
+		return h.WithAttrs(attrs)
+	})
+}
+
+func (sh *swapHandler) WithGroup(name string) slog.Handler {
+	return sh.with(func(h slog.Handler) slog.Handler {
+		return h.WithGroup(name)
+	})
+}
+
+// Reload replaces the configuration of a logger created by [NewLogger] and closes
+// the outputs it wrote to before. Loggers derived from it with With or WithGroup
+// pick up the change as well.
+func Reload(logger *slog.Logger, cfg Config) error {
+	sh, ok := logger.Handler().(*swapHandler)
+	if !ok {
+		return fmt.Errorf("logger was not created by NewLogger")
+	}
+
+	h, closeOutputs, err := newHandler(cfg)
+	if err != nil {
+		return fmt.Errorf("new handler: %w", err)
+	}
+	old := sh.root.Swap(&rootHandler{h: h, closeOutputs: closeOutputs})
+
+	if err := old.closeOutputs(); err != nil {
+		return fmt.Errorf("close previous outputs: %w", err)
+	}
+
+	return nil
+}
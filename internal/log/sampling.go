@@ -0,0 +1,250 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/pkg/config"
+)
+
+var droppedRecordsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "log_records_dropped_total",
+	Help: "Total number of log records dropped by sampling",
+}, []string{"level"})
+
+// SamplingConfig limits the number of records logged with the same level and message.
+// Errors and records of sampled traces are always logged.
+type SamplingConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Interval is the period over which records with the same level and message are counted.
+	Interval time.Duration `yaml:"interval"`
+	// First is the number of records with the same level and message logged per interval.
+	First int `yaml:"first"`
+	// Thereafter logs one of every Thereafter records after the first ones. 0 drops them all.
+	Thereafter int `yaml:"thereafter"`
+	// SummaryInterval is how often the number of dropped records is logged, if records
+	// were dropped. The last summary is logged when the logger is closed. 0 disables it.
+	SummaryInterval time.Duration `yaml:"summary_interval"`
+}
+
+func (c *SamplingConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	var errs []error
+	if c.Interval <= 0 {
+		errs = append(errs, config.Fieldf("interval", "must be greater than 0"))
+	}
+	if c.First < 0 {
+		errs = append(errs, config.Fieldf("first", "must not be negative"))
+	}
+	if c.Thereafter < 0 {
+		errs = append(errs, config.Fieldf("thereafter", "must not be negative"))
+	}
+	if c.SummaryInterval < 0 {
+		errs = append(errs, config.Fieldf("summary_interval", "must not be negative"))
+	}
+
+	return errors.Join(errs...)
+}
+
+var _ slog.Handler = (*samplingHandler)(nil)
+
+// samplingHandler logs the first records with the same level and message per interval
+// and samples the rest. The handlers derived with WithAttrs and WithGroup share the counts.
+// It must be closed to stop logging the summaries.
+type samplingHandler struct {
+	h       slog.Handler
+	sampler *sampler
+}
+
+type samplingKey struct {
+	level slog.Level
+	msg   string
+}
+
+type samplingCount struct {
+	start time.Time
+	n     int
+}
+
+// sampler holds the counts shared by a samplingHandler and the handlers derived from it.
+type sampler struct {
+	cfg SamplingConfig
+	// root is the handler the summary is logged with, without the attributes
+	// and groups of the derived handlers.
+	root slog.Handler
+
+	mu     sync.Mutex
+	counts map[samplingKey]*samplingCount
+	// lastPrune is when the counts of the intervals that ended were last deleted.
+	lastPrune   time.Time
+	dropped     map[slog.Level]int
+	lastSummary time.Time
+
+	// stop is closed by close to log the last summary and stop the summary loop.
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newSamplingHandler(h slog.Handler, cfg SamplingConfig) *samplingHandler {
+	now := time.Now()
+	s := &sampler{
+		cfg:         cfg,
+		root:        h,
+		counts:      make(map[samplingKey]*samplingCount),
+		lastPrune:   now,
+		dropped:     make(map[slog.Level]int),
+		lastSummary: now,
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	if cfg.SummaryInterval > 0 {
+		go s.run()
+	} else {
+		close(s.done)
+	}
+
+	return &samplingHandler{h: h, sampler: s}
+}
+
+// close logs the last summary and stops the summary loop.
+func (sh *samplingHandler) close() {
+	sh.sampler.closeOnce.Do(func() {
+		close(sh.sampler.stop)
+	})
+	<-sh.sampler.done
+}
+
+func (sh *samplingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return sh.h.Enabled(ctx, level)
+}
+
+func (sh *samplingHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < slog.LevelError &&
+		!trace.SpanContextFromContext(ctx).IsSampled() &&
+		!sh.sampler.keep(r) {
+		return nil
+	}
+
+	return sh.h.Handle(ctx, r)
+}
+
+func (sh *samplingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &samplingHandler{h: sh.h.WithAttrs(attrs), sampler: sh.sampler}
+}
+
+func (sh *samplingHandler) WithGroup(name string) slog.Handler {
+	return &samplingHandler{h: sh.h.WithGroup(name), sampler: sh.sampler}
+}
+
+// keep reports whether r is logged, counting it as dropped if not.
+func (s *sampler) keep(r slog.Record) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune(r.Time)
+
+	key := samplingKey{level: r.Level, msg: r.Message}
+	c, ok := s.counts[key]
+	if !ok {
+		c = &samplingCount{}
+		s.counts[key] = c
+	}
+	if r.Time.Sub(c.start) >= s.cfg.Interval {
+		c.start = r.Time
+		c.n = 0
+	}
+	c.n++
+
+	if c.n <= s.cfg.First || (s.cfg.Thereafter > 0 && (c.n-s.cfg.First)%s.cfg.Thereafter == 0) {
+		return true
+	}
+
+	s.dropped[r.Level]++
+	droppedRecordsTotal.WithLabelValues(strings.ToLower(r.Level.String())).Inc()
+
+	return false
+}
+
+// prune deletes the counts whose interval ended, once per interval, so messages that
+// are no longer logged do not hold memory. Such a count would be reset on its next record.
+// s.mu must be held.
+func (s *sampler) prune(now time.Time) {
+	if now.Sub(s.lastPrune) < s.cfg.Interval {
+		return
+	}
+
+	maps.DeleteFunc(s.counts, func(_ samplingKey, c *samplingCount) bool {
+		return now.Sub(c.start) >= s.cfg.Interval
+	})
+	s.lastPrune = now
+}
+
+// run logs a summary every summary interval until the sampler is closed,
+// and the last one when it is.
+func (s *sampler) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.cfg.SummaryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			s.logSummary(now)
+		case <-s.stop:
+			s.logSummary(time.Now())
+			return
+		}
+	}
+}
+
+// logSummary logs the summary of the records dropped since the last one, if any.
+func (s *sampler) logSummary(now time.Time) {
+	ctx := context.Background()
+	if r, ok := s.summary(now); ok && s.root.Enabled(ctx, r.Level) {
+		// Like slog.Logger, the error of a handler is ignored.
+		_ = s.root.Handle(ctx, r)
+	}
+}
+
+// summary returns a record reporting the records dropped since the last summary,
+// if records were dropped.
+func (s *sampler) summary(now time.Time) (slog.Record, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.dropped) == 0 {
+		return slog.Record{}, false
+	}
+
+	r := slog.NewRecord(now, slog.LevelWarn, "log records dropped by sampling", 0)
+	total := 0
+	for _, level := range slices.Sorted(maps.Keys(s.dropped)) {
+		n := s.dropped[level]
+		r.AddAttrs(slog.Int(strings.ToLower(level.String()), n))
+		total += n
+	}
+	r.AddAttrs(
+		slog.Int("total", total),
+		slog.Duration("since", now.Sub(s.lastSummary)),
+	)
+
+	clear(s.dropped)
+	s.lastSummary = now
+
+	return r, true
+}
@@ -0,0 +1,133 @@
+package log
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingHandler records the records it handles.
+type recordingHandler struct {
+	mu      sync.Mutex
+	records []slog.Record
+}
+
+func (h *recordingHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h *recordingHandler) Handle(_ context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.records = append(h.records, r)
+	return nil
+}
+
+func (h *recordingHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h *recordingHandler) WithGroup(string) slog.Handler      { return h }
+
+// messages returns the messages of the recorded records.
+func (h *recordingHandler) messages() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	msgs := make([]string, len(h.records))
+	for i, r := range h.records {
+		msgs[i] = r.Message
+	}
+	return msgs
+}
+
+func TestSampler(t *testing.T) {
+	rec := &recordingHandler{}
+	sh := newSamplingHandler(rec, SamplingConfig{Enabled: true, Interval: time.Hour, First: 2, Thereafter: 3})
+	defer sh.close()
+
+	start := time.Now()
+	var kept []int
+	for i := 1; i <= 8; i++ {
+		if sh.sampler.keep(slog.NewRecord(start, slog.LevelInfo, "repeated", 0)) {
+			kept = append(kept, i)
+		}
+	}
+	// Errors are never sampled.
+	for range 5 {
+		if err := sh.Handle(context.Background(), slog.NewRecord(start, slog.LevelError, "failed", 0)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if want := []int{1, 2, 5, 8}; !slices.Equal(kept, want) {
+		t.Errorf("kept records %v, want %v", kept, want)
+	}
+	if got := len(rec.messages()); got != 5 {
+		t.Errorf("logged %d errors, want 5", got)
+	}
+
+	// A new interval logs the first records again.
+	if !sh.sampler.keep(slog.NewRecord(start.Add(time.Hour), slog.LevelInfo, "repeated", 0)) {
+		t.Error("first record of a new interval dropped")
+	}
+}
+
+func TestSamplerPrunesCounts(t *testing.T) {
+	sh := newSamplingHandler(&recordingHandler{}, SamplingConfig{Enabled: true, Interval: time.Minute, First: 1})
+	defer sh.close()
+
+	start := time.Now()
+	for i := range 1000 {
+		sh.sampler.keep(slog.NewRecord(start, slog.LevelInfo, "user "+strconv.Itoa(i), 0))
+	}
+	sh.sampler.keep(slog.NewRecord(start.Add(time.Minute), slog.LevelInfo, "user 0", 0))
+
+	sh.sampler.mu.Lock()
+	defer sh.sampler.mu.Unlock()
+	if n := len(sh.sampler.counts); n != 1 {
+		t.Errorf("%d counts after an interval, want 1", n)
+	}
+}
+
+func TestSamplerSummary(t *testing.T) {
+	rec := &recordingHandler{}
+	sh := newSamplingHandler(rec, SamplingConfig{Enabled: true, Interval: time.Hour, SummaryInterval: 20 * time.Millisecond})
+
+	for range 3 {
+		if err := sh.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "dropped", 0)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The summary is logged without further records.
+	deadline := time.Now().Add(5 * time.Second)
+	for len(rec.messages()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	rec.mu.Lock()
+	if len(rec.records) != 1 {
+		t.Fatalf("logged %d records, want the summary", len(rec.records))
+	}
+	summary := rec.records[0]
+	rec.mu.Unlock()
+
+	attrs := make(map[string]int64)
+	summary.Attrs(func(a slog.Attr) bool {
+		if a.Value.Kind() == slog.KindInt64 {
+			attrs[a.Key] = a.Value.Int64()
+		}
+		return true
+	})
+	if attrs["info"] != 3 || attrs["total"] != 3 {
+		t.Errorf("summary attributes %v, want 3 info records dropped", attrs)
+	}
+
+	// The records dropped since the last summary are reported on close.
+	if err := sh.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelWarn, "dropped", 0)); err != nil {
+		t.Fatal(err)
+	}
+	sh.close()
+	if got := rec.messages(); len(got) != 2 {
+		t.Errorf("logged %v, want a second summary on close", got)
+	}
+}
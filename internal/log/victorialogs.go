@@ -0,0 +1,364 @@
+package log
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/pkg/config"
+)
+
+var (
+	shipperRecordsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "log_shipper_records_total",
+		Help: "Total number of log records handled by the VictoriaLogs shipper by result (sent, dropped, failed)",
+	}, []string{"result"})
+	shipperQueueLength = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "log_shipper_queue_length",
+		Help: "Number of log records waiting in the queue of the VictoriaLogs shipper",
+	})
+	shipperRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "log_shipper_requests_total",
+		Help: "Total number of requests sent to VictoriaLogs by response status, or error if none was received",
+	}, []string{"status"})
+	shipperRequestDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "log_shipper_request_duration_seconds",
+		Help:    "Duration of the requests sent to VictoriaLogs",
+		Buckets: prometheus.DefBuckets,
+	})
+)
+
+// Drop policies of a full queue.
+const (
+	// DropNewest drops the record being logged.
+	DropNewest = "newest"
+	// DropOldest drops the oldest record in the queue to make room for the record being logged.
+	DropOldest = "oldest"
+)
+
+// victoriaLogsInsertPath is the JSON lines ingestion endpoint of VictoriaLogs.
+const victoriaLogsInsertPath = "/insert/jsonline"
+
+// VictoriaLogsConfig configures the shipping of records to VictoriaLogs.
+type VictoriaLogsConfig struct {
+	// URL is the base URL of VictoriaLogs, e.g. http://localhost:9428.
+	URL string `yaml:"url"`
+	// FlushInterval is how often the queued records are sent.
+	FlushInterval time.Duration `yaml:"flush_interval"`
+	// BatchSize is the number of records sent in a request. A full batch is sent
+	// without waiting for the flush interval.
+	BatchSize int `yaml:"batch_size"`
+	// QueueSize is the number of records held in memory while they wait to be sent.
+	QueueSize int `yaml:"queue_size"`
+	// DropPolicy is which record is dropped when the queue is full: newest or oldest.
+	DropPolicy string `yaml:"drop_policy" enum:"newest,oldest"`
+	// Gzip compresses the requests.
+	Gzip bool `yaml:"gzip"`
+	// Timeout is the timeout of a request.
+	Timeout time.Duration `yaml:"timeout"`
+	Retry   RetryConfig   `yaml:"retry"`
+	// ShutdownTimeout bounds the time spent sending the queued records on shutdown.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+// RetryConfig configures the retries of failed requests. Requests are retried on
+// network errors, 429 and 5xx responses, with an exponential backoff and jitter.
+type RetryConfig struct {
+	// MaxAttempts is the number of attempts to send a batch, including the first one.
+	MaxAttempts    int           `yaml:"max_attempts"`
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+}
+
+func (c *VictoriaLogsConfig) Validate() error {
+	var errs []error
+	if u, err := url.Parse(c.URL); err != nil || u.Scheme == "" || u.Host == "" {
+		errs = append(errs, config.Fieldf("url", "must be an absolute URL"))
+	}
+	if c.FlushInterval <= 0 {
+		errs = append(errs, config.Fieldf("flush_interval", "must be greater than 0"))
+	}
+	if c.BatchSize <= 0 {
+		errs = append(errs, config.Fieldf("batch_size", "must be greater than 0"))
+	}
+	if c.QueueSize < c.BatchSize {
+		errs = append(errs, config.Fieldf("queue_size", "must be at least batch_size"))
+	}
+	switch c.DropPolicy {
+	case DropNewest, DropOldest:
+	default:
+		errs = append(errs, config.Fieldf("drop_policy", "must be one of %s or %s", DropNewest, DropOldest))
+	}
+	if c.Timeout <= 0 {
+		errs = append(errs, config.Fieldf("timeout", "must be greater than 0"))
+	}
+	if c.Retry.MaxAttempts < 1 {
+		errs = append(errs, config.Fieldf("retry.max_attempts", "must be at least 1"))
+	}
+	// A zero backoff stays zero when doubled, so the retries would not wait.
+	if c.Retry.MaxAttempts > 1 && c.Retry.InitialBackoff <= 0 {
+		errs = append(errs, config.Fieldf("retry.initial_backoff", "must be greater than 0 when retry.max_attempts is greater than 1"))
+	}
+	if c.Retry.MaxBackoff < c.Retry.InitialBackoff {
+		errs = append(errs, config.Fieldf("retry.max_backoff", "must be at least retry.initial_backoff"))
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, config.Fieldf("shutdown_timeout", "must be greater than 0"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// shipper sends the records written to it to VictoriaLogs in batches.
+// Each Write must hold exactly one JSON record, which is how the slog handlers write.
+// Writes never block: when the queue is full, a record is dropped according to the drop policy.
+type shipper struct {
+	cfg    VictoriaLogsConfig
+	url    string
+	client *http.Client
+
+	queue chan []byte
+	// stop is closed by Close to send the queued records and exit the loop.
+	stop chan struct{}
+	done chan struct{}
+
+	// mu guards the queue against sends after Close, and the drop of the oldest
+	// record against concurrent writers.
+	mu     sync.Mutex
+	closed bool
+}
+
+// newShipper starts a shipper. streamFields are the names of the fields
+// VictoriaLogs groups the records into streams by.
+func newShipper(cfg VictoriaLogsConfig, streamFields []string) *shipper {
+	query := url.Values{}
+	if len(streamFields) > 0 {
+		query.Set("_stream_fields", strings.Join(streamFields, ","))
+	}
+
+	s := &shipper{
+		cfg: cfg,
+		url: strings.TrimSuffix(cfg.URL, "/") + victoriaLogsInsertPath + "?" + query.Encode(),
+		// The client is not instrumented, which would log and trace the shipping itself.
+		client: &http.Client{Timeout: cfg.Timeout},
+		queue:  make(chan []byte, cfg.QueueSize),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go s.run()
+
+	return s
+}
+
+func (s *shipper) Write(p []byte) (int, error) {
+	// The handlers reuse their buffers.
+	record := bytes.Clone(p)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return 0, errors.New("shipper closed")
+	}
+
+	select {
+	case s.queue <- record:
+		shipperQueueLength.Inc()
+		return len(p), nil
+	default:
+	}
+
+	if s.cfg.DropPolicy == DropOldest {
+		select {
+		case <-s.queue:
+			shipperQueueLength.Dec()
+		default:
+		}
+		select {
+		case s.queue <- record:
+			shipperQueueLength.Inc()
+		default:
+		}
+	}
+	// Either the oldest or this record is dropped.
+	shipperRecordsTotal.WithLabelValues("dropped").Inc()
+
+	return len(p), nil
+}
+
+// Close sends the queued records and stops the shipper.
+func (s *shipper) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.stop)
+	s.mu.Unlock()
+
+	<-s.done
+
+	return nil
+}
+
+func (s *shipper) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	// ctx cancels the requests and the backoff when the shutdown timeout expires.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	batch := make([][]byte, 0, s.cfg.BatchSize)
+	flush := func() {
+		if len(batch) > 0 {
+			s.send(ctx, batch)
+			batch = batch[:0]
+		}
+	}
+
+	for {
+		select {
+		case record := <-s.queue:
+			shipperQueueLength.Dec()
+			batch = append(batch, record)
+			if len(batch) >= s.cfg.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-s.stop:
+			timer := time.AfterFunc(s.cfg.ShutdownTimeout, cancel)
+			defer timer.Stop()
+			for {
+				select {
+				case record := <-s.queue:
+					shipperQueueLength.Dec()
+					batch = append(batch, record)
+					if len(batch) >= s.cfg.BatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// send sends a batch, retrying on failures. The records of a batch that cannot be sent are dropped.
+func (s *shipper) send(ctx context.Context, batch [][]byte) {
+	body, err := s.encode(batch)
+	if err != nil {
+		s.fail(batch, err)
+		return
+	}
+
+	backoff := s.cfg.Retry.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		retry, err := s.post(ctx, body)
+		if err == nil {
+			shipperRecordsTotal.WithLabelValues("sent").Add(float64(len(batch)))
+			return
+		}
+		if !retry || attempt >= s.cfg.Retry.MaxAttempts {
+			s.fail(batch, err)
+			return
+		}
+
+		// Full jitter spreads the retries of several instances.
+		wait := time.Duration(rand.Int64N(int64(backoff) + 1)) //nolint:gosec // Jitter does not need a secure source.
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			s.fail(batch, ctx.Err())
+			return
+		}
+		backoff = min(backoff*2, s.cfg.Retry.MaxBackoff)
+	}
+}
+
+func (s *shipper) encode(batch [][]byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := io.Writer(&buf)
+
+	var zw *gzip.Writer
+	if s.cfg.Gzip {
+		zw = gzip.NewWriter(&buf)
+		w = zw
+	}
+	for _, record := range batch {
+		if _, err := w.Write(record); err != nil {
+			return nil, fmt.Errorf("encode batch: %w", err)
+		}
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			return nil, fmt.Errorf("encode batch: %w", err)
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// post sends a request and reports whether it can be retried if it failed.
+func (s *shipper) post(ctx context.Context, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/stream+json")
+	if s.cfg.Gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	start := time.Now()
+	resp, err := s.client.Do(req)
+	shipperRequestDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		shipperRequestsTotal.WithLabelValues("error").Inc()
+		return ctx.Err() == nil, fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	shipperRequestsTotal.WithLabelValues(strconv.Itoa(resp.StatusCode)).Inc()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+	return retry, fmt.Errorf("unexpected status: %s", resp.Status)
+}
+
+// fail drops the records of a batch that could not be sent. The error is written
+// to stderr, since logging it would be shipped as well.
+func (s *shipper) fail(batch [][]byte, err error) {
+	shipperRecordsTotal.WithLabelValues("failed").Add(float64(len(batch)))
+	fmt.Fprintf(os.Stderr, "ship %d log records to VictoriaLogs: %v\n", len(batch), err)
+}
+
+// streamFieldNames returns the sorted names of the stream fields.
+func streamFieldNames(fields map[string]string) []string {
+	return slices.Sorted(maps.Keys(fields))
+}
@@ -0,0 +1,388 @@
+package log
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// insertRequest is a request received by the VictoriaLogs stand-in.
+type insertRequest struct {
+	path         string
+	streamFields string
+	encoding     string
+	records      []string
+}
+
+// newVictoriaLogs starts a stand-in for VictoriaLogs sending the requests it receives
+// on the returned channel. status returns the status of the n-th request, from 1.
+func newVictoriaLogs(t *testing.T, status func(n int) int) (*httptest.Server, <-chan insertRequest) {
+	t.Helper()
+
+	requests := make(chan insertRequest, 100)
+	n := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The shipper sends one request at a time.
+		n++
+
+		body := io.Reader(r.Body)
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Errorf("read gzip body: %v", err)
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			body = zr
+		}
+		var records []string
+		scanner := bufio.NewScanner(body)
+		for scanner.Scan() {
+			records = append(records, scanner.Text())
+		}
+		if err := scanner.Err(); err != nil {
+			t.Errorf("read body: %v", err)
+		}
+
+		requests <- insertRequest{
+			path:         r.URL.Path,
+			streamFields: r.URL.Query().Get("_stream_fields"),
+			encoding:     r.Header.Get("Content-Encoding"),
+			records:      records,
+		}
+		w.WriteHeader(status(n))
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, requests
+}
+
+func noContent(int) int { return http.StatusNoContent }
+
+func testVictoriaLogsConfig(url string) VictoriaLogsConfig {
+	return VictoriaLogsConfig{
+		URL:           url,
+		FlushInterval: time.Hour,
+		BatchSize:     10,
+		QueueSize:     10,
+		DropPolicy:    DropNewest,
+		Timeout:       time.Second,
+		Retry: RetryConfig{
+			MaxAttempts:    1,
+			InitialBackoff: time.Millisecond,
+			MaxBackoff:     10 * time.Millisecond,
+		},
+		ShutdownTimeout: time.Second,
+	}
+}
+
+// writeRecords writes the records numbered from to to, excluded.
+func writeRecords(t *testing.T, s *shipper, from, to int) {
+	t.Helper()
+
+	for i := from; i < to; i++ {
+		if _, err := s.Write([]byte(`{"n":` + strconv.Itoa(i) + "}\n")); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+}
+
+// records returns the records numbered in ns as the stand-in receives them.
+func records(ns ...int) []string {
+	records := make([]string, len(ns))
+	for i, n := range ns {
+		records[i] = `{"n":` + strconv.Itoa(n) + "}"
+	}
+	return records
+}
+
+func receive(t *testing.T, requests <-chan insertRequest) insertRequest {
+	t.Helper()
+
+	select {
+	case req := <-requests:
+		return req
+	case <-time.After(5 * time.Second):
+		t.Fatal("no request received")
+		return insertRequest{}
+	}
+}
+
+func TestShipperBatchSize(t *testing.T) {
+	srv, requests := newVictoriaLogs(t, noContent)
+	cfg := testVictoriaLogsConfig(srv.URL)
+	cfg.BatchSize = 3
+
+	s := newShipper(cfg, []string{"app", "env"})
+	defer s.Close()
+	writeRecords(t, s, 0, 6)
+
+	// Full batches are sent without waiting for the flush interval.
+	for i, want := range [][]string{records(0, 1, 2), records(3, 4, 5)} {
+		req := receive(t, requests)
+		if req.path != victoriaLogsInsertPath {
+			t.Errorf("request %d: path = %s, want %s", i, req.path, victoriaLogsInsertPath)
+		}
+		if req.streamFields != "app,env" {
+			t.Errorf("request %d: _stream_fields = %q, want app,env", i, req.streamFields)
+		}
+		if !slices.Equal(req.records, want) {
+			t.Errorf("request %d: records = %v, want %v", i, req.records, want)
+		}
+	}
+}
+
+func TestShipperFlushInterval(t *testing.T) {
+	srv, requests := newVictoriaLogs(t, noContent)
+	cfg := testVictoriaLogsConfig(srv.URL)
+	cfg.FlushInterval = 20 * time.Millisecond
+
+	s := newShipper(cfg, nil)
+	defer s.Close()
+	writeRecords(t, s, 0, 2)
+
+	if req := receive(t, requests); !slices.Equal(req.records, records(0, 1)) {
+		t.Errorf("records = %v, want %v", req.records, records(0, 1))
+	}
+}
+
+func TestShipperGzip(t *testing.T) {
+	srv, requests := newVictoriaLogs(t, noContent)
+	cfg := testVictoriaLogsConfig(srv.URL)
+	cfg.Gzip = true
+
+	s := newShipper(cfg, nil)
+	writeRecords(t, s, 0, 3)
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	req := receive(t, requests)
+	if req.encoding != "gzip" {
+		t.Errorf("Content-Encoding = %q, want gzip", req.encoding)
+	}
+	if !slices.Equal(req.records, records(0, 1, 2)) {
+		t.Errorf("records = %v, want %v", req.records, records(0, 1, 2))
+	}
+}
+
+func TestShipperRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		// requests is the number of requests sent for the batch.
+		requests int
+		sent     bool
+	}{
+		{name: "429 and 5xx", statuses: []int{http.StatusTooManyRequests, http.StatusServiceUnavailable, http.StatusNoContent}, requests: 3, sent: true},
+		{name: "attempts exhausted", statuses: []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable}, requests: 3},
+		{name: "4xx", statuses: []int{http.StatusBadRequest}, requests: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, requests := newVictoriaLogs(t, func(n int) int { return tt.statuses[n-1] })
+			cfg := testVictoriaLogsConfig(srv.URL)
+			cfg.Retry.MaxAttempts = 3
+
+			sent := testutil.ToFloat64(shipperRecordsTotal.WithLabelValues("sent"))
+			failed := testutil.ToFloat64(shipperRecordsTotal.WithLabelValues("failed"))
+
+			s := newShipper(cfg, nil)
+			writeRecords(t, s, 0, 2)
+			if err := s.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			if len(requests) != tt.requests {
+				t.Errorf("requests = %d, want %d", len(requests), tt.requests)
+			}
+			for range len(requests) {
+				if req := <-requests; !slices.Equal(req.records, records(0, 1)) {
+					t.Errorf("records = %v, want the same batch on every attempt", req.records)
+				}
+			}
+
+			wantSent, wantFailed := 0.0, 2.0
+			if tt.sent {
+				wantSent, wantFailed = 2, 0
+			}
+			if got := testutil.ToFloat64(shipperRecordsTotal.WithLabelValues("sent")) - sent; got != wantSent {
+				t.Errorf("sent records = %v, want %v", got, wantSent)
+			}
+			if got := testutil.ToFloat64(shipperRecordsTotal.WithLabelValues("failed")) - failed; got != wantFailed {
+				t.Errorf("failed records = %v, want %v", got, wantFailed)
+			}
+		})
+	}
+}
+
+func TestShipperDropPolicy(t *testing.T) {
+	tests := []struct {
+		policy string
+		want   []string
+	}{
+		{policy: DropNewest, want: records(0, 1, 2)},
+		{policy: DropOldest, want: records(0, 2, 3)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			// The first request blocks until release is closed, so the records
+			// written meanwhile fill the queue.
+			received := make(chan struct{})
+			release := make(chan struct{})
+			var got []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				scanner := bufio.NewScanner(r.Body)
+				for scanner.Scan() {
+					got = append(got, scanner.Text())
+				}
+				if len(got) == 1 {
+					close(received)
+					<-release
+				}
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer srv.Close()
+
+			cfg := testVictoriaLogsConfig(srv.URL)
+			cfg.BatchSize = 1
+			cfg.QueueSize = 2
+			cfg.DropPolicy = tt.policy
+
+			dropped := testutil.ToFloat64(shipperRecordsTotal.WithLabelValues("dropped"))
+
+			s := newShipper(cfg, nil)
+			writeRecords(t, s, 0, 1)
+			select {
+			case <-received:
+			case <-time.After(5 * time.Second):
+				t.Fatal("no request received")
+			}
+			writeRecords(t, s, 1, 4)
+			close(release)
+			if err := s.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("records = %v, want %v", got, tt.want)
+			}
+			if got := testutil.ToFloat64(shipperRecordsTotal.WithLabelValues("dropped")) - dropped; got != 1 {
+				t.Errorf("dropped records = %v, want 1", got)
+			}
+		})
+	}
+}
+
+func TestShipperClose(t *testing.T) {
+	srv, requests := newVictoriaLogs(t, noContent)
+	cfg := testVictoriaLogsConfig(srv.URL)
+	cfg.BatchSize = 2
+
+	s := newShipper(cfg, nil)
+	writeRecords(t, s, 0, 5)
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// The queued records are sent in batches before Close returns.
+	var got []string
+	for range len(requests) {
+		req := <-requests
+		if len(req.records) > cfg.BatchSize {
+			t.Errorf("batch of %d records, want at most %d", len(req.records), cfg.BatchSize)
+		}
+		got = append(got, req.records...)
+	}
+	if want := records(0, 1, 2, 3, 4); !slices.Equal(got, want) {
+		t.Errorf("records = %v, want %v", got, want)
+	}
+
+	if _, err := s.Write([]byte(`{"n":5}` + "\n")); err == nil {
+		t.Error("Write() after Close error = nil, want an error")
+	}
+}
+
+func TestShipperShutdownTimeout(t *testing.T) {
+	srv, _ := newVictoriaLogs(t, func(int) int { return http.StatusServiceUnavailable })
+	cfg := testVictoriaLogsConfig(srv.URL)
+	cfg.Retry = RetryConfig{MaxAttempts: 1000, InitialBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+	cfg.ShutdownTimeout = 100 * time.Millisecond
+
+	s := newShipper(cfg, nil)
+	writeRecords(t, s, 0, 1)
+
+	start := time.Now()
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Close() took %s, want it bounded by the shutdown timeout", elapsed)
+	}
+}
+
+func TestVictoriaLogsConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*VictoriaLogsConfig)
+		// field is the key of the expected error, or empty if the config is valid.
+		field string
+	}{
+		{name: "valid", modify: func(*VictoriaLogsConfig) {}},
+		{
+			name:   "no backoff without retries",
+			modify: func(c *VictoriaLogsConfig) { c.Retry = RetryConfig{MaxAttempts: 1} },
+		},
+		{
+			name:   "no backoff with retries",
+			modify: func(c *VictoriaLogsConfig) { c.Retry.MaxAttempts, c.Retry.InitialBackoff = 3, 0 },
+			field:  "retry.initial_backoff",
+		},
+		{
+			name:   "max backoff below initial backoff",
+			modify: func(c *VictoriaLogsConfig) { c.Retry.MaxBackoff = c.Retry.InitialBackoff / 2 },
+			field:  "retry.max_backoff",
+		},
+		{
+			name:   "no shutdown timeout",
+			modify: func(c *VictoriaLogsConfig) { c.ShutdownTimeout = 0 },
+			field:  "shutdown_timeout",
+		},
+		{
+			name:   "queue smaller than batch",
+			modify: func(c *VictoriaLogsConfig) { c.QueueSize = c.BatchSize - 1 },
+			field:  "queue_size",
+		},
+		{
+			name:   "relative url",
+			modify: func(c *VictoriaLogsConfig) { c.URL = "localhost:9428" },
+			field:  "url",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testVictoriaLogsConfig("http://localhost:9428")
+			tt.modify(&cfg)
+
+			err := cfg.Validate()
+			if tt.field == "" {
+				if err != nil {
+					t.Fatalf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.field+":") {
+				t.Fatalf("Validate() error = %v, want an error on %s", err, tt.field)
+			}
+		})
+	}
+}
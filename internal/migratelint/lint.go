@@ -0,0 +1,108 @@
+// Package migratelint checks goose SQL migrations for operations that lock tables
+// for a long time, destroy data or cannot be rolled back.
+//
+// A violation is suppressed by a comment directly above the statement:
+//
+//	-- lint:ignore drop-column the column is unused since v1.2
+//	ALTER TABLE users DROP COLUMN legacy_id;
+//
+// or for the whole file with -- lint:ignore-file <rule>[,<rule>...] <reason>.
+package migratelint
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Violation is a risky operation found in a migration file.
+type Violation struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s:%d: %s: %s", v.File, v.Line, v.Rule, v.Message)
+}
+
+// Lint checks the SQL migration files in dir of fsys.
+func Lint(fsys fs.FS, dir string) ([]Violation, error) {
+	names, err := fs.Glob(fsys, path.Join(dir, "*.sql"))
+	if err != nil {
+		return nil, fmt.Errorf("list migrations: %w", err)
+	}
+	slices.Sort(names)
+
+	var violations []Violation
+	for _, name := range names {
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", name, err)
+		}
+
+		v, err := LintFile(name, content)
+		if err != nil {
+			return nil, err
+		}
+		violations = append(violations, v...)
+	}
+
+	return violations, nil
+}
+
+// LintFile checks the content of the SQL migration file name.
+func LintFile(name string, content []byte) ([]Violation, error) {
+	f, err := parse(name, content)
+	if err != nil {
+		return nil, err
+	}
+
+	var violations []Violation
+	report := func(s *statement, line int, rule, msg string) {
+		if f.ignored[rule] || (s != nil && s.ignored[rule]) {
+			return
+		}
+		violations = append(violations, Violation{File: name, Line: line, Rule: rule, Message: msg})
+	}
+
+	for _, r := range fileRules {
+		if msg := r.check(f); msg != "" {
+			report(nil, 1, r.name, msg)
+		}
+	}
+	for _, s := range f.statements {
+		sql := s.normalized()
+		for _, r := range statementRules {
+			if r.upOnly && s.section != sectionUp {
+				continue
+			}
+			if msg := r.check(f, s, sql); msg != "" {
+				report(s, s.line, r.name, msg)
+			}
+		}
+	}
+
+	return violations, nil
+}
+
+// Rules lists the names and descriptions of all rules.
+func Rules() map[string]string {
+	rules := make(map[string]string, len(fileRules)+len(statementRules))
+	for _, r := range fileRules {
+		rules[r.name] = r.description
+	}
+	for _, r := range statementRules {
+		rules[r.name] = r.description
+	}
+
+	return rules
+}
+
+// hasWord reports whether the normalized sql contains the keyword sequence words.
+func hasWord(sql, words string) bool {
+	return strings.Contains(" "+sql+" ", " "+words+" ")
+}
@@ -0,0 +1,182 @@
+package migratelint
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+const (
+	sectionNone = ""
+	sectionUp   = "up"
+	sectionDown = "down"
+)
+
+const (
+	gooseAnnotation      = "-- +goose"
+	ignoreAnnotation     = "-- lint:ignore"
+	ignoreFileAnnotation = "-- lint:ignore-file"
+)
+
+// file is a parsed goose SQL migration file.
+type file struct {
+	name       string
+	statements []*statement
+	// hasDown reports whether the file has the -- +goose Down annotation.
+	hasDown bool
+	// noTransaction reports whether the file is annotated with -- +goose NO TRANSACTION.
+	noTransaction bool
+	// ignored are the rules suppressed for the whole file with -- lint:ignore-file.
+	ignored map[string]bool
+	// createdTables are the tables created in the Up section, in upper case.
+	createdTables map[string]bool
+}
+
+// statement is a single SQL statement of a migration file.
+type statement struct {
+	section string
+	// line is the 1-based line number the statement starts at.
+	line int
+	sql  string
+	// inBlock reports whether the statement is enclosed in StatementBegin and StatementEnd.
+	inBlock bool
+	// split reports whether goose splits the statement at the semicolons in its dollar-quoted body
+	// because it is not enclosed in StatementBegin and StatementEnd.
+	split bool
+	// ignored are the rules suppressed with -- lint:ignore comments directly above the statement.
+	ignored map[string]bool
+}
+
+// normalized returns the statement in upper case with comments removed and whitespace collapsed.
+func (s *statement) normalized() string {
+	var b strings.Builder
+	for line := range strings.SplitSeq(s.sql, "\n") {
+		if i := strings.Index(line, "--"); i >= 0 {
+			line = line[:i]
+		}
+		b.WriteString(line)
+		b.WriteByte(' ')
+	}
+
+	return strings.ToUpper(strings.Join(strings.Fields(b.String()), " "))
+}
+
+// parse splits a goose SQL migration into statements the way goose does:
+// statements end with a semicolon at the end of a line, unless they are enclosed
+// in -- +goose StatementBegin and -- +goose StatementEnd.
+func parse(name string, content []byte) (*file, error) {
+	f := &file{
+		name:          name,
+		ignored:       map[string]bool{},
+		createdTables: map[string]bool{},
+	}
+
+	var (
+		section = sectionNone
+		inBlock bool
+		current *statement
+		pending = map[string]bool{}
+		buf     strings.Builder
+	)
+
+	flush := func() {
+		if current != nil && strings.TrimSpace(buf.String()) != "" {
+			current.sql = strings.TrimSpace(buf.String())
+			f.statements = append(f.statements, current)
+		}
+		current = nil
+		buf.Reset()
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case strings.HasPrefix(trimmed, gooseAnnotation):
+			switch annotation := strings.ToUpper(strings.TrimSpace(strings.TrimPrefix(trimmed, gooseAnnotation))); annotation {
+			case "UP":
+				flush()
+				section = sectionUp
+			case "DOWN":
+				flush()
+				section, f.hasDown = sectionDown, true
+			case "STATEMENTBEGIN":
+				flush()
+				inBlock = true
+			case "STATEMENTEND":
+				if !inBlock {
+					return nil, fmt.Errorf("%s:%d: StatementEnd without StatementBegin", name, lineNo)
+				}
+				flush()
+				inBlock = false
+			case "NO TRANSACTION":
+				f.noTransaction = true
+			}
+			continue
+		case strings.HasPrefix(trimmed, ignoreFileAnnotation):
+			for _, rule := range ignoredRules(strings.TrimPrefix(trimmed, ignoreFileAnnotation)) {
+				f.ignored[rule] = true
+			}
+			continue
+		case strings.HasPrefix(trimmed, ignoreAnnotation):
+			for _, rule := range ignoredRules(strings.TrimPrefix(trimmed, ignoreAnnotation)) {
+				pending[rule] = true
+			}
+			continue
+		case current == nil && (trimmed == "" || strings.HasPrefix(trimmed, "--")):
+			continue
+		}
+
+		if current == nil {
+			current = &statement{
+				section: section,
+				line:    lineNo,
+				inBlock: inBlock,
+				ignored: pending,
+			}
+			pending = map[string]bool{}
+		}
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+
+		if !inBlock && strings.HasSuffix(trimmed, ";") {
+			// Keep a dollar-quoted body together to report the statement once.
+			if len(dollarQuotePattern.FindAllString(buf.String(), -1))%2 == 1 {
+				current.split = true
+				continue
+			}
+			flush()
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read %s: %w", name, err)
+	}
+	if inBlock {
+		return nil, fmt.Errorf("%s: StatementBegin without StatementEnd", name)
+	}
+	flush()
+
+	for _, s := range f.statements {
+		if m := createTablePattern.FindStringSubmatch(s.normalized()); m != nil && s.section == sectionUp {
+			f.createdTables[m[3]] = true
+		}
+	}
+
+	return f, nil
+}
+
+// ignoredRules parses the rule list of an ignore annotation, e.g. " drop-column,drop-table reason".
+// The list is followed by an optional free-form reason.
+func ignoredRules(s string) []string {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	return strings.Split(fields[0], ",")
+}
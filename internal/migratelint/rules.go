@@ -0,0 +1,205 @@
+package migratelint
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Rule names, used in violations and lint:ignore comments.
+const (
+	RuleMissingDown             = "missing-down"
+	RuleIndexNotConcurrent      = "index-not-concurrent"
+	RuleConcurrentInTransaction = "concurrent-in-transaction"
+	RuleNotNullWithoutDefault   = "not-null-without-default"
+	RuleColumnTypeChange        = "column-type-change"
+	RuleDropColumn              = "drop-column"
+	RuleDropTable               = "drop-table"
+	RuleStatementNeedsBlock     = "statement-needs-block"
+	RuleTransactionControl      = "transaction-control"
+)
+
+type fileRule struct {
+	name        string
+	description string
+	check       func(f *file) string
+}
+
+type statementRule struct {
+	name        string
+	description string
+	// upOnly applies the rule to the Up section only.
+	upOnly bool
+	// check returns the violation message for statement s, or an empty string.
+	// sql is the normalized statement.
+	check func(f *file, s *statement, sql string) string
+}
+
+var fileRules = []fileRule{
+	{
+		name:        RuleMissingDown,
+		description: "the migration has no Down section or it is empty, so it cannot be rolled back",
+		check: func(f *file) string {
+			if !f.hasDown {
+				return "no -- +goose Down section"
+			}
+			for _, s := range f.statements {
+				if s.section == sectionDown {
+					return ""
+				}
+			}
+			return "the -- +goose Down section is empty"
+		},
+	},
+}
+
+var (
+	// createIndexPattern matches CREATE [UNIQUE] INDEX [CONCURRENTLY].
+	createIndexPattern = regexp.MustCompile(`\bCREATE (UNIQUE )?INDEX( CONCURRENTLY)?\b`)
+	// addColumnPattern matches the start of the ADD [COLUMN] clauses of ALTER TABLE.
+	addColumnPattern = regexp.MustCompile(`\bADD (COLUMN )?(IF NOT EXISTS )?`)
+	// alterTypePattern matches ALTER [COLUMN] x [SET DATA] TYPE.
+	alterTypePattern = regexp.MustCompile(`\bALTER (COLUMN )?\S+ (SET DATA )?TYPE\b`)
+	// indexTablePattern matches the table of CREATE INDEX ... ON [ONLY] table.
+	indexTablePattern = regexp.MustCompile(`\bON (ONLY )?([^\s(]+)`)
+	// createTablePattern matches the table of CREATE TABLE [IF NOT EXISTS] table.
+	createTablePattern = regexp.MustCompile(`^CREATE (UNLOGGED )?TABLE (IF NOT EXISTS )?([^\s(]+)`)
+	// dollarQuotePattern matches the delimiters of dollar-quoted strings, e.g. $$ or $body$.
+	dollarQuotePattern = regexp.MustCompile(`\$[A-Za-z_0-9]*\$`)
+)
+
+var statementRules = []statementRule{
+	{
+		name:        RuleIndexNotConcurrent,
+		description: "CREATE INDEX without CONCURRENTLY blocks writes to the table while the index is built",
+		upOnly:      true,
+		check: func(f *file, _ *statement, sql string) string {
+			m := createIndexPattern.FindStringSubmatch(sql)
+			if m == nil || m[2] != "" {
+				return ""
+			}
+			// A table created by the same migration has no rows to lock.
+			if on := indexTablePattern.FindStringSubmatch(sql); on != nil && f.createdTables[on[2]] {
+				return ""
+			}
+			return "CREATE INDEX without CONCURRENTLY blocks writes to the table, use CREATE INDEX CONCURRENTLY"
+		},
+	},
+	{
+		name:        RuleConcurrentInTransaction,
+		description: "CONCURRENTLY cannot run inside the transaction goose wraps the migration in",
+		check: func(f *file, _ *statement, sql string) string {
+			if f.noTransaction || !hasWord(sql, "CONCURRENTLY") {
+				return ""
+			}
+			return "CONCURRENTLY cannot run in a transaction, annotate the file with -- +goose NO TRANSACTION"
+		},
+	},
+	{
+		name:        RuleNotNullWithoutDefault,
+		description: "adding a NOT NULL column without a DEFAULT fails on tables with rows",
+		upOnly:      true,
+		check: func(_ *file, _ *statement, sql string) string {
+			if !strings.HasPrefix(sql, "ALTER TABLE") {
+				return ""
+			}
+			for _, def := range addColumnDefs(sql) {
+				if strings.HasPrefix(def, "ADD CONSTRAINT") {
+					continue
+				}
+				if hasWord(def, "NOT NULL") && !hasWord(def, "DEFAULT") {
+					return "adding a NOT NULL column without a DEFAULT fails on tables with rows, add a DEFAULT or backfill first"
+				}
+			}
+			return ""
+		},
+	},
+	{
+		name:        RuleColumnTypeChange,
+		description: "changing the type of a column may rewrite the table under an exclusive lock",
+		upOnly:      true,
+		check: func(_ *file, _ *statement, sql string) string {
+			if !strings.HasPrefix(sql, "ALTER TABLE") || !alterTypePattern.MatchString(sql) {
+				return ""
+			}
+			return "changing the type of a column may rewrite the table under an exclusive lock"
+		},
+	},
+	{
+		name:        RuleDropColumn,
+		description: "DROP COLUMN in an Up section destroys data and breaks running code that reads the column",
+		upOnly:      true,
+		check: func(_ *file, _ *statement, sql string) string {
+			if !strings.HasPrefix(sql, "ALTER TABLE") || !hasWord(sql, "DROP COLUMN") {
+				return ""
+			}
+			return "DROP COLUMN destroys data and breaks running code that still reads the column"
+		},
+	},
+	{
+		name:        RuleDropTable,
+		description: "DROP TABLE in an Up section destroys data",
+		upOnly:      true,
+		check: func(_ *file, _ *statement, sql string) string {
+			if !strings.HasPrefix(sql, "DROP TABLE") {
+				return ""
+			}
+			return "DROP TABLE destroys data"
+		},
+	},
+	{
+		name:        RuleStatementNeedsBlock,
+		description: "a statement with semicolons in its body is outside StatementBegin/StatementEnd, so goose splits it",
+		check: func(_ *file, s *statement, _ string) string {
+			if !s.split {
+				return ""
+			}
+			return "the statement has semicolons in a dollar-quoted body, enclose it in -- +goose StatementBegin and StatementEnd"
+		},
+	},
+	{
+		name:        RuleTransactionControl,
+		description: "BEGIN, COMMIT or ROLLBACK in a migration goose already runs in a transaction",
+		check: func(f *file, _ *statement, sql string) string {
+			if f.noTransaction {
+				return ""
+			}
+			switch strings.TrimSuffix(sql, ";") {
+			case "BEGIN", "BEGIN TRANSACTION", "START TRANSACTION", "COMMIT", "END", "ROLLBACK":
+				return "goose already runs the migration in a transaction, remove the transaction control statement"
+			}
+			return ""
+		},
+	},
+}
+
+// addColumnDefs returns the ADD clauses of the normalized ALTER TABLE statement sql,
+// each up to the comma separating it from the next clause. Commas in parentheses,
+// e.g. numeric(10,2), and in string literals do not end a clause.
+func addColumnDefs(sql string) []string {
+	var defs []string
+	for _, loc := range addColumnPattern.FindAllStringIndex(sql, -1) {
+		defs = append(defs, sql[loc[0]:clauseEnd(sql, loc[1])])
+	}
+
+	return defs
+}
+
+// clauseEnd returns the index of the comma or semicolon ending the clause of sql starting at start.
+func clauseEnd(sql string, start int) int {
+	depth, quoted := 0, false
+	for i := start; i < len(sql); i++ {
+		switch c := sql[i]; {
+		case c == '\'':
+			quoted = !quoted
+		case quoted:
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case (c == ',' || c == ';') && depth <= 0:
+			return i
+		}
+	}
+
+	return len(sql)
+}
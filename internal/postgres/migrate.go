@@ -1,29 +1,480 @@
 package postgres
 
 import (
+	"bufio"
+	"context"
+	"database/sql"
 	"embed"
+	"errors"
 	"fmt"
+	"io/fs"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/jackc/pgx/v5/stdlib"
 	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/pkg/config"
 )
 
 //go:embed migration/*.sql
 var migrationFS embed.FS
 
-// Migrate migrates the database
-func Migrate(pool *pgxpool.Pool) error {
-	goose.SetBaseFS(migrationFS)
+const (
+	directionUp   = "up"
+	directionDown = "down"
+)
+
+// migrationDir is the directory of the migration files in migrationFS.
+const migrationDir = "migration"
+
+// MigrationMinConns is the smallest pool a Migrator can run with: the migration lock
+// holds a connection for the whole operation while the migrations run on another.
+const MigrationMinConns = 2
+
+// MigrationConfig configures how migrations are applied.
+type MigrationConfig struct {
+	// LockTimeout is how long to wait for the migration advisory lock held by another
+	// process before giving up.
+	LockTimeout time.Duration `yaml:"lock_timeout"`
+	// LockRetryInterval is how often the advisory lock is tried while waiting for it.
+	LockRetryInterval time.Duration `yaml:"lock_retry_interval"`
+}
+
+func (c *MigrationConfig) Validate() error {
+	var errs []error
+
+	// The session locker works with whole seconds.
+	if c.LockRetryInterval < time.Second {
+		errs = append(errs, config.Fieldf("lock_retry_interval", "must be at least 1s"))
+	}
+	if c.LockTimeout < c.LockRetryInterval {
+		errs = append(errs, config.Fieldf("lock_timeout", "must be at least lock_retry_interval"))
+	}
+
+	return errors.Join(errs...)
+}
+
+var tracer = otel.Tracer("internal/postgres")
+
+var (
+	migrationDurationSeconds = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "migration_duration_seconds",
+		Help: "Duration of the migrations run by the migrator in seconds",
+	}, []string{"version", "direction"})
+	migrationsAppliedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "migrations_applied_total",
+		Help: "Total number of migrations applied by direction",
+	}, []string{"direction"})
+	schemaVersion = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "schema_version",
+		Help: "Version of the most recently applied migration",
+	})
+)
+
+// Migrator applies the embedded migrations to the database.
+// Concurrent migrators, e.g. several replicas or reruns of the migration job,
+// are serialized with a Postgres advisory lock held for the whole operation.
+//
+// Migrations are applied one at a time, each in its own span, and recorded
+// in the migration metrics. The checksum of every applied migration file is
+// recorded for [Verify].
+// Use [NewMigrator] to create a new instance of Migrator.
+type Migrator struct {
+	pool     *pgxpool.Pool
+	db       *sql.DB
+	locker   lock.SessionLocker
+	fsys     fs.FS
+	provider *goose.Provider
+}
+
+// NewMigrator initializes a Migrator for the database of pool.
+// The output of goose is logged to logger.
+// Call Close when done to release the resources of the migrator; the pool is left open.
+func NewMigrator(pool *pgxpool.Pool, cfg MigrationConfig, logger *slog.Logger) (*Migrator, error) {
+	if maxConns := pool.Config().MaxConns; maxConns < MigrationMinConns {
+		return nil, fmt.Errorf("pool max conns is %d, the migrator needs at least %d", maxConns, MigrationMinConns)
+	}
+
+	fsys, err := fs.Sub(migrationFS, migrationDir)
+	if err != nil {
+		return nil, fmt.Errorf("open migrations: %w", err)
+	}
+
+	period := uint64(cfg.LockRetryInterval / time.Second)
+	failureThreshold := uint64(math.Ceil(float64(cfg.LockTimeout) / float64(cfg.LockRetryInterval)))
+	locker, err := lock.NewPostgresSessionLocker(lock.WithLockTimeout(period, failureThreshold))
+	if err != nil {
+		return nil, fmt.Errorf("new session locker: %w", err)
+	}
+
+	db := stdlib.OpenDBFromPool(pool)
+	// The lock is taken by the Migrator rather than goose, which would take it for each step.
+	provider, err := goose.NewProvider(goose.DialectPostgres, db, fsys,
+		goose.WithSlog(logger),
+		goose.WithVerbose(true),
+	)
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("new goose provider: %w", err)
+	}
+
+	return &Migrator{
+		pool:     pool,
+		db:       db,
+		locker:   locker,
+		fsys:     fsys,
+		provider: provider,
+	}, nil
+}
+
+// Up applies all pending migrations.
+func (m *Migrator) Up(ctx context.Context) ([]*goose.MigrationResult, error) {
+	return m.UpTo(ctx, math.MaxInt64)
+}
+
+// UpTo applies all pending migrations up to and including version.
+func (m *Migrator) UpTo(ctx context.Context, version int64) ([]*goose.MigrationResult, error) {
+	return m.run(ctx, "up", func(ctx context.Context) ([]*goose.MigrationResult, error) {
+		var results []*goose.MigrationResult
+		for {
+			// The next migration applied by UpByOne is the first pending one.
+			pending, err := m.pending(ctx, version)
+			if err != nil {
+				return results, err
+			}
+			if len(pending) == 0 {
+				return results, nil
+			}
+
+			result, err := m.step(ctx, directionUp, m.provider.UpByOne)
+			if err != nil {
+				return results, err
+			}
+			results = append(results, result)
+		}
+	})
+}
+
+// Down rolls back the most recently applied migration.
+func (m *Migrator) Down(ctx context.Context) ([]*goose.MigrationResult, error) {
+	return m.run(ctx, "down", func(ctx context.Context) ([]*goose.MigrationResult, error) {
+		result, err := m.step(ctx, directionDown, m.provider.Down)
+		if err != nil {
+			return nil, err
+		}
+
+		return []*goose.MigrationResult{result}, nil
+	})
+}
+
+// DownTo rolls back all migrations down to, but not including, version.
+func (m *Migrator) DownTo(ctx context.Context, version int64) ([]*goose.MigrationResult, error) {
+	return m.run(ctx, "down_to", func(ctx context.Context) ([]*goose.MigrationResult, error) {
+		var results []*goose.MigrationResult
+		for {
+			current, err := m.provider.GetDBVersion(ctx)
+			if err != nil {
+				return results, fmt.Errorf("get version: %w", err)
+			}
+			if current <= version {
+				return results, nil
+			}
+
+			result, err := m.step(ctx, directionDown, m.provider.Down)
+			if errors.Is(err, goose.ErrNoNextVersion) {
+				return results, nil
+			}
+			if err != nil {
+				return results, err
+			}
+			results = append(results, result)
+		}
+	})
+}
+
+// Redo rolls back the most recently applied migration and applies it again.
+func (m *Migrator) Redo(ctx context.Context) ([]*goose.MigrationResult, error) {
+	return m.run(ctx, "redo", func(ctx context.Context) ([]*goose.MigrationResult, error) {
+		down, err := m.step(ctx, directionDown, m.provider.Down)
+		if err != nil {
+			return nil, fmt.Errorf("down: %w", err)
+		}
+
+		up, err := m.step(ctx, directionUp, m.provider.UpByOne)
+		if err != nil {
+			return []*goose.MigrationResult{down}, fmt.Errorf("up: %w", err)
+		}
+
+		return []*goose.MigrationResult{down, up}, nil
+	})
+}
+
+// run runs the migrations of operation op in a span with the migration lock held,
+// and records the resulting schema version.
+func (m *Migrator) run(
+	ctx context.Context,
+	op string,
+	fn func(context.Context) ([]*goose.MigrationResult, error),
+) (results []*goose.MigrationResult, err error) {
+	ctx, span := tracer.Start(ctx, "migrate."+op)
+	defer span.End()
+
+	unlock, err := m.lock(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	defer func() {
+		if uerr := unlock(); uerr != nil {
+			err = errors.Join(err, uerr)
+		}
+	}()
+
+	if _, err := m.pool.Exec(ctx, createChecksumTableQuery); err != nil {
+		err = fmt.Errorf("create checksum table: %w", err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	results, err = fn(ctx)
+	if cerr := m.recordMissingChecksums(ctx); cerr != nil {
+		err = errors.Join(err, cerr)
+	}
+	if err != nil && !errors.Is(err, goose.ErrNoNextVersion) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	if version, verr := m.provider.GetDBVersion(ctx); verr == nil {
+		schemaVersion.Set(float64(version))
+		span.SetAttributes(attribute.Int64("db.migration.schema_version", version))
+	}
+
+	return results, err
+}
+
+// lock takes the migration advisory lock on a dedicated connection, so concurrent
+// migrators cannot interleave between the steps of an operation.
+// It returns a function releasing the lock.
+func (m *Migrator) lock(ctx context.Context) (func() error, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("acquire lock connection: %w", err)
+	}
+
+	if err := m.locker.SessionLock(ctx, conn); err != nil {
+		return nil, errors.Join(fmt.Errorf("acquire migration lock: %w", err), conn.Close())
+	}
+
+	return func() error {
+		// The lock is released even if ctx has been canceled.
+		if err := m.locker.SessionUnlock(context.WithoutCancel(ctx), conn); err != nil {
+			return errors.Join(fmt.Errorf("release migration lock: %w", err), conn.Close())
+		}
+		return conn.Close()
+	}, nil
+}
+
+// step applies a single migration in direction with fn in its own span and records its metrics.
+func (m *Migrator) step(
+	ctx context.Context,
+	direction string,
+	fn func(context.Context) (*goose.MigrationResult, error),
+) (*goose.MigrationResult, error) {
+	ctx, span := tracer.Start(ctx, "migration."+direction)
+	defer span.End()
+
+	result, err := fn(ctx)
+	if partialErr, ok := errors.AsType[*goose.PartialError](err); ok {
+		result = partialErr.Failed
+	}
+
+	if result != nil {
+		version := strconv.FormatInt(result.Source.Version, 10)
+		span.SetName(fmt.Sprintf("migration.%s %s", direction, version))
+		span.SetAttributes(
+			attribute.Int64("db.migration.version", result.Source.Version),
+			attribute.String("db.migration.source", result.Source.Path),
+		)
+		migrationDurationSeconds.WithLabelValues(version, direction).Set(result.Duration.Seconds())
+		if result.Error == nil {
+			migrationsAppliedTotal.WithLabelValues(direction).Inc()
+		}
+	}
+	if err == nil {
+		err = m.recordChecksum(ctx, result)
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return result, err
+}
 
-	if err := goose.SetDialect("pgx"); err != nil {
-		return fmt.Errorf("set dialect: %w", err)
+// recordChecksum records the checksum of the migration applied by result,
+// or forgets it when the migration was rolled back.
+func (m *Migrator) recordChecksum(ctx context.Context, result *goose.MigrationResult) error {
+	if result.Direction == directionDown {
+		if _, err := m.pool.Exec(ctx, deleteChecksumQuery, result.Source.Version); err != nil {
+			return fmt.Errorf("delete checksum of %d: %w", result.Source.Version, err)
+		}
+		return nil
 	}
 
-	stdDB := stdlib.OpenDBFromPool(pool)
-	if err := goose.Up(stdDB, "migration"); err != nil {
-		return fmt.Errorf("migrate: %w", err)
+	checksum, err := migrationChecksum(m.fsys, result.Source.Path)
+	if err != nil {
+		return err
+	}
+	if _, err := m.pool.Exec(ctx, upsertChecksumQuery, result.Source.Version, checksum); err != nil {
+		return fmt.Errorf("record checksum of %d: %w", result.Source.Version, err)
+	}
+
+	return nil
+}
+
+// recordMissingChecksums records the checksums of applied migrations that have none,
+// e.g. applied before checksums were recorded, from the current migration files.
+func (m *Migrator) recordMissingChecksums(ctx context.Context) error {
+	statuses, err := m.provider.Status(ctx)
+	if err != nil {
+		return fmt.Errorf("status: %w", err)
+	}
+
+	for _, s := range statuses {
+		if s.State != goose.StateApplied {
+			continue
+		}
+
+		checksum, err := migrationChecksum(m.fsys, s.Source.Path)
+		if err != nil {
+			return err
+		}
+		if _, err := m.pool.Exec(ctx, insertMissingChecksumQuery, s.Source.Version, checksum); err != nil {
+			return fmt.Errorf("record checksum of %d: %w", s.Source.Version, err)
+		}
 	}
 
 	return nil
 }
+
+// Status returns the state of every migration.
+func (m *Migrator) Status(ctx context.Context) ([]*goose.MigrationStatus, error) {
+	return m.provider.Status(ctx)
+}
+
+// Verify compares the database against the embedded migrations, see [Verify].
+func (m *Migrator) Verify(ctx context.Context, opts VerifyOptions) (*DriftReport, error) {
+	return Verify(ctx, m.pool, opts)
+}
+
+// Version returns the version of the most recently applied migration.
+func (m *Migrator) Version(ctx context.Context) (int64, error) {
+	return m.provider.GetDBVersion(ctx)
+}
+
+// PlannedMigration is a pending migration that would be applied.
+type PlannedMigration struct {
+	Version int64
+	Path    string
+	// SQL is the up section of the migration file.
+	SQL string
+}
+
+// Plan returns the pending migrations that UpTo(version) would apply, without applying them.
+// Use [math.MaxInt64] as version to plan Up.
+func (m *Migrator) Plan(ctx context.Context, version int64) ([]PlannedMigration, error) {
+	pending, err := m.pending(ctx, version)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := make([]PlannedMigration, 0, len(pending))
+	for _, s := range pending {
+		content, err := fs.ReadFile(m.fsys, s.Source.Path)
+		if err != nil {
+			return nil, fmt.Errorf("read migration %d: %w", s.Source.Version, err)
+		}
+
+		plan = append(plan, PlannedMigration{
+			Version: s.Source.Version,
+			Path:    s.Source.Path,
+			SQL:     upSQL(string(content)),
+		})
+	}
+
+	return plan, nil
+}
+
+// pending returns the pending migrations up to and including version, in ascending order.
+func (m *Migrator) pending(ctx context.Context, version int64) ([]*goose.MigrationStatus, error) {
+	statuses, err := m.provider.Status(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("status: %w", err)
+	}
+
+	var pending []*goose.MigrationStatus
+	for _, s := range statuses {
+		if s.State == goose.StatePending && s.Source.Version <= version {
+			pending = append(pending, s)
+		}
+	}
+
+	return pending, nil
+}
+
+// upSQL returns the statements of the "-- +goose Up" section of a SQL migration file.
+func upSQL(content string) string {
+	var (
+		b    strings.Builder
+		inUp bool
+	)
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		annotation, ok := strings.CutPrefix(strings.TrimSpace(line), "-- +goose")
+		if ok {
+			switch strings.TrimSpace(annotation) {
+			case "Up":
+				inUp = true
+			case "Down":
+				inUp = false
+			}
+			continue
+		}
+		if inUp {
+			b.WriteString(line)
+			b.WriteByte('\n')
+		}
+	}
+
+	return strings.TrimSpace(b.String())
+}
+
+// Close releases the resources of the migrator.
+func (m *Migrator) Close() error {
+	return m.provider.Close()
+}
+
+// CreateMigration creates a new empty SQL migration file named after name in dir.
+func CreateMigration(dir, name string) error {
+	if name == "" {
+		return errors.New("migration name is required")
+	}
+
+	return goose.Create(nil, dir, name, "sql")
+}
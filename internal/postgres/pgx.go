@@ -2,77 +2,174 @@ package postgres
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"net/url"
+	"maps"
+	"net"
+	"os"
 	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/exaring/otelpgx"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/pkg/config"
 )
 
+// sslRootCertSystem is the ssl_root_cert value that selects the system certificate pool.
+const sslRootCertSystem = "system"
+
 type Config struct {
+	// URL is a full connection string in URL or keyword/value format.
+	// Settings given in URL take precedence over the individual fields below.
+	URL string `yaml:"url" secret:"true"`
+	// Service is the name of a service in the pg_service.conf file to read settings from.
+	Service string `yaml:"service"`
+	// PassFile is the path of the pgpass file used to look up the password when none is given.
+	// It defaults to ~/.pgpass.
+	PassFile string `yaml:"passfile"`
+
 	Host string `yaml:"host"`
 	Port int    `yaml:"port"`
 	User string `yaml:"user"`
 	//nolint:gosec
-	Password string `yaml:"password"`
+	Password string `yaml:"password" secret:"true"`
 	DB       string `yaml:"db"`
-	SSLMode  string `yaml:"ssl_mode"`
+
+	SSLMode string `yaml:"ssl_mode" enum:"disable,allow,prefer,require,verify-ca,verify-full"`
+	// SSLRootCert is the path of the CA certificate(s) used to verify the server,
+	// or "system" to use the system certificate pool.
+	SSLRootCert string `yaml:"ssl_root_cert"`
+	// SSLCert and SSLKey are the paths of the client certificate and its private key.
+	SSLCert string `yaml:"ssl_cert"`
+	SSLKey  string `yaml:"ssl_key"`
+	// SSLPassword decrypts SSLKey if it is encrypted.
+	SSLPassword string `yaml:"ssl_password" secret:"true"`
 
 	MaxConns        int32         `yaml:"max_conns"`
 	MinConns        int32         `yaml:"min_conns"`
 	MaxConnLifetime time.Duration `yaml:"max_conn_lifetime"`
 	MaxConnIdleTime time.Duration `yaml:"max_conn_idle_time"`
+
+	Replicas ReplicasConfig `yaml:"replicas"`
 }
 
 func (p *Config) Validate() error {
-	if p.Host == "" {
-		return fmt.Errorf("host is required")
+	var errs []error
+
+	if p.URL != "" {
+		if _, err := parseDSN(p.URL); err != nil {
+			errs = append(errs, config.Field("url", err))
+		}
 	}
-	if p.Port == 0 {
-		return fmt.Errorf("port is required")
+
+	// The connection settings can be omitted when they are given by the URL or service.
+	// The password is looked up in the pgpass file when empty.
+	if p.URL == "" && p.Service == "" {
+		if p.Host == "" {
+			errs = append(errs, config.Fieldf("host", "is required"))
+		}
+		if p.Port == 0 {
+			errs = append(errs, config.Fieldf("port", "is required"))
+		}
+		if p.User == "" {
+			errs = append(errs, config.Fieldf("user", "is required"))
+		}
+		if p.DB == "" {
+			errs = append(errs, config.Fieldf("db", "is required"))
+		}
 	}
-	if p.User == "" {
-		return fmt.Errorf("user is required")
+
+	allowedSSLModes := []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+	if p.SSLMode != "" && !slices.Contains(allowedSSLModes, p.SSLMode) {
+		errs = append(errs, config.Fieldf("ssl_mode", "must be one of the following values: %s", strings.Join(allowedSSLModes, ", ")))
 	}
-	if p.Password == "" {
-		return fmt.Errorf("password is required")
+	if (p.SSLMode == "verify-ca" || p.SSLMode == "verify-full") && p.SSLRootCert == "" {
+		errs = append(errs, config.Fieldf("ssl_root_cert", "is required when ssl mode is %s, use %q for the system certificate pool", p.SSLMode, sslRootCertSystem))
 	}
-	if p.DB == "" {
-		return fmt.Errorf("db is required")
+	if p.SSLCert != "" && p.SSLKey == "" {
+		errs = append(errs, config.Fieldf("ssl_key", "is required when ssl cert is set"))
 	}
-
-	allowedSSLModes := []string{"disable", "require", "verify-full"}
-	if p.SSLMode != "" && !slices.Contains(allowedSSLModes, p.SSLMode) {
-		return fmt.Errorf("ssl mode must be one of the following values: %s", strings.Join(allowedSSLModes, ", "))
+	if p.SSLKey != "" && p.SSLCert == "" {
+		errs = append(errs, config.Fieldf("ssl_cert", "is required when ssl key is set"))
+	}
+	for _, f := range []struct{ key, path string }{
+		{"ssl_root_cert", p.SSLRootCert},
+		{"ssl_cert", p.SSLCert},
+		{"ssl_key", p.SSLKey},
+	} {
+		if f.path == "" || f.path == sslRootCertSystem {
+			continue
+		}
+		if _, err := os.Stat(f.path); err != nil {
+			errs = append(errs, config.Field(f.key, err))
+		}
 	}
 	if p.MaxConns <= 0 {
-		return fmt.Errorf("max conns must be greater than 0")
+		errs = append(errs, config.Fieldf("max_conns", "must be greater than 0"))
 	}
 	if p.MinConns <= 0 {
-		return fmt.Errorf("min conns must be greater than 0")
+		errs = append(errs, config.Fieldf("min_conns", "must be greater than 0"))
 	}
 	if p.MaxConnLifetime <= 0 {
-		return fmt.Errorf("max conn lifetime must be greater than 0")
+		errs = append(errs, config.Fieldf("max_conn_lifetime", "must be greater than 0"))
 	}
 	if p.MaxConnIdleTime <= 0 {
-		return fmt.Errorf("max conn idle time must be greater than 0")
+		errs = append(errs, config.Fieldf("max_conn_idle_time", "must be greater than 0"))
 	}
 
-	return nil
+	return errors.Join(errs...)
 }
 
 // NewPgxPool creates a new pgxpool.Pool with the given configuration.
 func NewPgxPool(ctx context.Context, cfg Config) (*pgxpool.Pool, error) {
-	connStr := connectionString(cfg)
+	connStr, err := connectionString(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("connection string: %w", err)
+	}
+
+	pool, err := newPool(ctx, cfg, connStr, RolePrimary)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a context with timeout for ping
+	pingCtx, pingCancel := context.WithTimeout(ctx, 5*time.Second)
+	defer pingCancel()
+
+	if err := pool.Ping(pingCtx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("ping database: %w", err)
+	}
+
+	if err := recordPoolStats(pingCtx, pool, RolePrimary); err != nil {
+		pool.Close()
+		return nil, err
+	}
+
+	return pool, nil
+}
+
+// newPool creates a pool for connStr without connecting to the database.
+// role labels the spans and metrics of the pool.
+func newPool(ctx context.Context, cfg Config, connStr string, role string) (*pgxpool.Pool, error) {
 	pgConf, err := pgxpool.ParseConfig(connStr)
 	if err != nil {
 		return nil, fmt.Errorf("parse config: %w", err)
 	}
 
-	pgConf.ConnConfig.Tracer = newTracer()
+	pgConf.ConnConfig.Tracer = newTracer(role)
+
+	reloader, err := newTLSReloader(connStr)
+	if err != nil {
+		return nil, fmt.Errorf("new TLS reloader: %w", err)
+	}
+	if reloader != nil {
+		pgConf.BeforeConnect = reloader.beforeConnect
+	}
 
 	pgConf.MaxConns = cfg.MaxConns
 	pgConf.MinConns = cfg.MinConns
@@ -84,32 +181,75 @@ func NewPgxPool(ctx context.Context, cfg Config) (*pgxpool.Pool, error) {
 		return nil, fmt.Errorf("create pool: %w", err)
 	}
 
-	if err := otelpgx.RecordStats(pool); err != nil {
-		return nil, fmt.Errorf("record database stats: %w", err)
+	return pool, nil
+}
+
+// recordPoolStats records the pool statistics labeled with its role, its address
+// and the negotiated TLS version when the database can be reached.
+func recordPoolStats(ctx context.Context, pool *pgxpool.Pool, role string) error {
+	connConfig := pool.Config().ConnConfig
+	statsAttrs := []attribute.KeyValue{
+		attribute.String(poolRoleKey, role),
+		attribute.String("server.address", net.JoinHostPort(connConfig.Host, strconv.Itoa(int(connConfig.Port)))),
 	}
 
-	// Create a context with timeout for ping
-	pingCtx, pingCancel := context.WithTimeout(ctx, 5*time.Second)
-	defer pingCancel()
+	tlsCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
 
-	if err := pool.Ping(pingCtx); err != nil {
-		return nil, fmt.Errorf("ping database: %w", err)
+	if version, err := tlsVersion(tlsCtx, pool); err == nil && version != "" {
+		statsAttrs = append(statsAttrs, attribute.String("tls.protocol.version", version))
 	}
 
-	return pool, nil
+	if err := otelpgx.RecordStats(pool, otelpgx.WithStatsAttributes(statsAttrs...)); err != nil {
+		return fmt.Errorf("record database stats: %w", err)
+	}
+
+	return nil
 }
 
 // connectionString constructs the connection string for the database.
-func connectionString(cfg Config) string {
-	u := url.URL{
-		Scheme: "postgres",
-		User:   url.UserPassword(cfg.User, cfg.Password),
-		Host:   fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
-		Path:   cfg.DB,
-	}
-	q := u.Query()
-	q.Add("sslmode", cfg.SSLMode)
-	u.RawQuery = q.Encode()
-
-	return u.String()
+func connectionString(cfg Config) (string, error) {
+	settings, err := connectionSettings(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	return formatDSN(settings), nil
+}
+
+// connectionSettings returns the libpq settings of the database.
+// The individual fields of cfg are merged with the settings of cfg.URL,
+// which take precedence.
+func connectionSettings(cfg Config) (map[string]string, error) {
+	settings := map[string]string{}
+	set := func(key, value string) {
+		if value != "" {
+			settings[key] = value
+		}
+	}
+
+	set("service", cfg.Service)
+	set("passfile", cfg.PassFile)
+	set("host", cfg.Host)
+	if cfg.Port != 0 {
+		set("port", strconv.Itoa(cfg.Port))
+	}
+	set("user", cfg.User)
+	set("password", cfg.Password)
+	set("dbname", cfg.DB)
+	set("sslmode", cfg.SSLMode)
+	set("sslrootcert", cfg.SSLRootCert)
+	set("sslcert", cfg.SSLCert)
+	set("sslkey", cfg.SSLKey)
+	set("sslpassword", cfg.SSLPassword)
+
+	if cfg.URL != "" {
+		urlSettings, err := parseDSN(cfg.URL)
+		if err != nil {
+			return nil, fmt.Errorf("parse url: %w", err)
+		}
+		maps.Copy(settings, urlSettings)
+	}
+
+	return settings, nil
 }
@@ -0,0 +1,330 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/pkg/config"
+)
+
+const (
+	// RolePrimary labels the pool of the primary database.
+	RolePrimary = "primary"
+	// RoleReplica labels the pools of the read replicas.
+	RoleReplica = "replica"
+)
+
+const (
+	BalancingRoundRobin = "round_robin"
+	BalancingLeastConns = "least_conns"
+)
+
+// replicaStatusQuery returns whether the server is a standby and its replication lag in seconds.
+// A standby that has replayed everything it received is not lagging, even if
+// the primary has been idle for a while.
+const replicaStatusQuery = `-- name: ReplicaStatus :one
+SELECT pg_is_in_recovery(), CASE
+	WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+	ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+END::float8`
+
+var (
+	routedQueriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "db_routed_total",
+		Help: "Total number of database operations routed to a pool by role",
+	}, []string{"role"})
+	replicaHealthy = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "db_replica_healthy",
+		Help: "Whether a read replica is reachable and within the maximum lag (1) or not (0)",
+	}, []string{"replica"})
+	replicaLagSeconds = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "db_replica_lag_seconds",
+		Help: "Replication lag of a read replica in seconds",
+	}, []string{"replica"})
+)
+
+// ReplicasConfig configures the read replicas of the primary database.
+// The replicas share the credentials and pool settings of the primary.
+type ReplicasConfig struct {
+	// Endpoints are the host:port addresses of the replicas.
+	Endpoints []string `yaml:"endpoints"`
+	// Balancing selects how read-only work is spread over healthy replicas.
+	Balancing string `yaml:"balancing" enum:"round_robin,least_conns"`
+	// MaxLag is the replication lag above which a replica is not used. Zero disables the check.
+	MaxLag              time.Duration `yaml:"max_lag"`
+	HealthCheckInterval time.Duration `yaml:"health_check_interval"`
+}
+
+func (r *ReplicasConfig) Validate() error {
+	if len(r.Endpoints) == 0 {
+		return nil
+	}
+
+	var errs []error
+
+	for i, endpoint := range r.Endpoints {
+		if _, _, err := net.SplitHostPort(endpoint); err != nil {
+			errs = append(errs, config.Field(fmt.Sprintf("endpoints.%d", i), err))
+		}
+	}
+
+	allowedBalancing := []string{BalancingRoundRobin, BalancingLeastConns}
+	if r.Balancing != "" && !slices.Contains(allowedBalancing, r.Balancing) {
+		errs = append(errs, config.Fieldf("balancing", "must be one of the following values: %s", strings.Join(allowedBalancing, ", ")))
+	}
+	if r.MaxLag < 0 {
+		errs = append(errs, config.Fieldf("max_lag", "must not be negative"))
+	}
+	if r.HealthCheckInterval <= 0 {
+		errs = append(errs, config.Fieldf("health_check_interval", "must be greater than 0"))
+	}
+
+	return errors.Join(errs...)
+}
+
+type readOnlyCtxKey struct{}
+
+// WithReadOnly returns a context that routes the work done through a [Router] to a replica.
+func WithReadOnly(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readOnlyCtxKey{}, true)
+}
+
+// IsReadOnly reports whether ctx was created by [WithReadOnly].
+func IsReadOnly(ctx context.Context) bool {
+	readOnly, _ := ctx.Value(readOnlyCtxKey{}).(bool)
+	return readOnly
+}
+
+// replica is a read replica pool with its latest health check result.
+type replica struct {
+	addr    string
+	pool    *pgxpool.Pool
+	healthy atomic.Bool
+	// notStandby is set while the endpoint is not a standby, to log it once.
+	notStandby atomic.Bool
+}
+
+// Router routes read-only work to a healthy read replica and everything else to the primary.
+// It falls back to the primary when no replica is healthy.
+// Use [NewRouter] to create a new instance of Router.
+type Router struct {
+	logger    *slog.Logger
+	primary   *pgxpool.Pool
+	replicas  []*replica
+	balancing string
+	maxLag    time.Duration
+	next      atomic.Uint64
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewRouter creates the primary pool and one pool per replica endpoint, and starts
+// checking the health of the replicas in the background.
+// Replicas that are down at startup are checked again later instead of failing.
+// Endpoints that are not a standby, e.g. a primary listed by mistake, are never used and logged.
+func NewRouter(ctx context.Context, cfg Config, logger *slog.Logger) (*Router, error) {
+	primary, err := NewPgxPool(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("new primary pool: %w", err)
+	}
+
+	r := &Router{
+		logger:    logger,
+		primary:   primary,
+		balancing: cfg.Replicas.Balancing,
+		maxLag:    cfg.Replicas.MaxLag,
+	}
+
+	settings, err := connectionSettings(cfg)
+	if err != nil {
+		r.Close()
+		return nil, fmt.Errorf("connection settings: %w", err)
+	}
+
+	for _, endpoint := range cfg.Replicas.Endpoints {
+		host, port, err := net.SplitHostPort(endpoint)
+		if err != nil {
+			r.Close()
+			return nil, fmt.Errorf("parse replica endpoint %q: %w", endpoint, err)
+		}
+
+		replicaSettings := maps.Clone(settings)
+		replicaSettings["host"] = host
+		replicaSettings["port"] = port
+
+		pool, err := newPool(ctx, cfg, formatDSN(replicaSettings), RoleReplica)
+		if err != nil {
+			r.Close()
+			return nil, fmt.Errorf("new replica pool %s: %w", endpoint, err)
+		}
+
+		rep := &replica{addr: endpoint, pool: pool}
+		r.replicas = append(r.replicas, rep)
+		r.checkReplica(ctx, rep)
+
+		if err := recordPoolStats(ctx, pool, RoleReplica); err != nil {
+			r.Close()
+			return nil, err
+		}
+	}
+
+	if len(r.replicas) > 0 {
+		checkCtx, cancel := context.WithCancel(context.Background())
+		r.cancel = cancel
+		r.wg.Go(func() {
+			r.checkReplicas(checkCtx, cfg.Replicas.HealthCheckInterval)
+		})
+	}
+
+	return r, nil
+}
+
+// Primary returns the pool of the primary database.
+func (r *Router) Primary() *pgxpool.Pool {
+	return r.primary
+}
+
+// Pool returns a replica pool if ctx was created by [WithReadOnly],
+// otherwise the primary pool.
+func (r *Router) Pool(ctx context.Context) *pgxpool.Pool {
+	if IsReadOnly(ctx) {
+		return r.Reader()
+	}
+
+	routedQueriesTotal.WithLabelValues(RolePrimary).Inc()
+	return r.primary
+}
+
+// Reader returns the pool of a healthy replica, or the primary pool if none is healthy.
+func (r *Router) Reader() *pgxpool.Pool {
+	healthy := make([]*replica, 0, len(r.replicas))
+	for _, rep := range r.replicas {
+		if rep.healthy.Load() {
+			healthy = append(healthy, rep)
+		}
+	}
+
+	if len(healthy) == 0 {
+		routedQueriesTotal.WithLabelValues(RolePrimary).Inc()
+		return r.primary
+	}
+
+	var rep *replica
+	switch r.balancing {
+	case BalancingLeastConns:
+		rep = slices.MinFunc(healthy, func(a, b *replica) int {
+			return int(a.pool.Stat().AcquiredConns() - b.pool.Stat().AcquiredConns())
+		})
+	default:
+		rep = healthy[r.next.Add(1)%uint64(len(healthy))]
+	}
+
+	routedQueriesTotal.WithLabelValues(RoleReplica).Inc()
+	return rep.pool
+}
+
+// Exec executes sql on the pool selected by [Router.Pool].
+func (r *Router) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	return r.Pool(ctx).Exec(ctx, sql, args...)
+}
+
+// Query executes sql on the pool selected by [Router.Pool].
+func (r *Router) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return r.Pool(ctx).Query(ctx, sql, args...)
+}
+
+// QueryRow executes sql on the pool selected by [Router.Pool].
+func (r *Router) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return r.Pool(ctx).QueryRow(ctx, sql, args...)
+}
+
+// BeginTx starts a transaction. Read-only transactions are started on a replica.
+func (r *Router) BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+	if txOptions.AccessMode == pgx.ReadOnly {
+		return r.Reader().BeginTx(ctx, txOptions)
+	}
+
+	return r.Pool(ctx).BeginTx(ctx, txOptions)
+}
+
+// Close stops the health checks and closes all pools.
+func (r *Router) Close() {
+	if r.cancel != nil {
+		r.cancel()
+	}
+	r.wg.Wait()
+
+	for _, rep := range r.replicas {
+		rep.pool.Close()
+	}
+	r.primary.Close()
+}
+
+func (r *Router) checkReplicas(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, rep := range r.replicas {
+				r.checkReplica(ctx, rep)
+			}
+		}
+	}
+}
+
+// checkReplica marks rep healthy if it is a standby that can be queried and its lag is within the maximum.
+func (r *Router) checkReplica(ctx context.Context, rep *replica) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var (
+		inRecovery bool
+		lagSeconds float64
+	)
+	err := rep.pool.QueryRow(ctx, replicaStatusQuery).Scan(&inRecovery, &lagSeconds)
+
+	healthy := err == nil && inRecovery
+	if err == nil {
+		if !inRecovery {
+			// pg_last_wal_receive_lsn is null on a primary, so its lag would be 0.
+			if !rep.notStandby.Swap(true) {
+				r.logger.ErrorContext(ctx, "read replica endpoint is not a standby, not routing to it",
+					slog.String("replica", rep.addr),
+				)
+			}
+		} else {
+			rep.notStandby.Store(false)
+			replicaLagSeconds.WithLabelValues(rep.addr).Set(lagSeconds)
+			if r.maxLag > 0 && lagSeconds > r.maxLag.Seconds() {
+				healthy = false
+			}
+		}
+	}
+
+	rep.healthy.Store(healthy)
+	if healthy {
+		replicaHealthy.WithLabelValues(rep.addr).Set(1)
+	} else {
+		replicaHealthy.WithLabelValues(rep.addr).Set(0)
+	}
+}
@@ -0,0 +1,191 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// SeedModeUpsert inserts the generated users and updates the ones that already exist.
+	SeedModeUpsert = "upsert"
+	// SeedModeTruncate deletes all users before inserting the generated ones.
+	SeedModeTruncate = "truncate"
+)
+
+// seedEpoch is the latest creation time of the generated users.
+// It is fixed so the same seed always generates the same rows.
+var seedEpoch = time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+var userColumns = []string{"id", "email", "name", "password_hash", "created_at", "updated_at"}
+
+const truncateUsersQuery = `-- name: TruncateUsers :exec
+TRUNCATE users`
+
+const createSeedUsersQuery = `-- name: CreateSeedUsers :exec
+CREATE TEMPORARY TABLE seed_users (LIKE users INCLUDING DEFAULTS) ON COMMIT DROP`
+
+const upsertSeedUsersQuery = `-- name: UpsertSeedUsers :exec
+INSERT INTO users (id, email, name, password_hash, created_at, updated_at)
+SELECT id, email, name, password_hash, created_at, updated_at FROM seed_users
+ON CONFLICT (email) DO UPDATE SET
+	name = EXCLUDED.name,
+	password_hash = EXCLUDED.password_hash,
+	created_at = EXCLUDED.created_at,
+	updated_at = EXCLUDED.updated_at`
+
+var (
+	firstNames = []string{
+		"Olivia", "Liam", "Emma", "Noah", "Ava", "Oliver", "Sophia", "Elijah", "Isabella", "James",
+		"Mia", "William", "Amelia", "Lucas", "Harper", "Henry", "Evelyn", "Minh", "Linh", "Tuan",
+		"Hana", "Yuki", "Sofia", "Mateo", "Aisha", "Omar", "Priya", "Arjun", "Chloe", "Leo",
+	}
+	lastNames = []string{
+		"Smith", "Johnson", "Williams", "Brown", "Jones", "Garcia", "Miller", "Davis", "Martinez", "Lopez",
+		"Wilson", "Anderson", "Taylor", "Thomas", "Moore", "Nguyen", "Tran", "Le", "Pham", "Sato",
+		"Suzuki", "Kim", "Park", "Patel", "Singh", "Khan", "Muller", "Rossi", "Silva", "Dubois",
+	}
+	emailDomains = []string{"example.com", "example.org", "example.net", "mail.example.com"}
+)
+
+// SeedOptions configures [SeedUsers].
+type SeedOptions struct {
+	// Count is the number of users to generate.
+	Count int
+	// Seed makes the generated users deterministic: the same seed generates the same users.
+	Seed uint64
+	// BatchSize is the number of users copied per round trip.
+	BatchSize int
+	// Mode is SeedModeUpsert or SeedModeTruncate.
+	Mode string
+}
+
+func (o SeedOptions) validate() error {
+	var errs []error
+
+	if o.Count <= 0 {
+		errs = append(errs, errors.New("count must be greater than 0"))
+	}
+	if o.BatchSize <= 0 {
+		errs = append(errs, errors.New("batch size must be greater than 0"))
+	}
+	if o.Mode != SeedModeUpsert && o.Mode != SeedModeTruncate {
+		errs = append(errs, fmt.Errorf("mode must be %s or %s", SeedModeUpsert, SeedModeTruncate))
+	}
+
+	return errors.Join(errs...)
+}
+
+// SeedUsers generates opts.Count users deterministically from opts.Seed and copies them
+// into the users table in batches, logging the progress after each batch.
+// Rerunning it with the same seed updates the same users in upsert mode, keyed by email.
+func SeedUsers(ctx context.Context, pool *pgxpool.Pool, logger *slog.Logger, opts SeedOptions) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+
+	ctx, span := tracer.Start(ctx, "seed.users")
+	defer span.End()
+
+	if opts.Mode == SeedModeTruncate {
+		if _, err := pool.Exec(ctx, truncateUsersQuery); err != nil {
+			return fmt.Errorf("truncate users: %w", err)
+		}
+	}
+
+	gen := newUserGenerator(opts.Seed)
+	start := time.Now()
+	for done := 0; done < opts.Count; {
+		rows := make([][]any, 0, min(opts.BatchSize, opts.Count-done))
+		for range cap(rows) {
+			rows = append(rows, gen.next())
+		}
+
+		if err := copyUsers(ctx, pool, opts.Mode, rows); err != nil {
+			return fmt.Errorf("copy users %d-%d: %w", done+1, done+len(rows), err)
+		}
+		done += len(rows)
+
+		elapsed := time.Since(start)
+		logger.InfoContext(ctx, "seeded users",
+			slog.Int("done", done),
+			slog.Int("total", opts.Count),
+			slog.Float64("percent", float64(done)*100/float64(opts.Count)),
+			slog.Float64("rows_per_second", float64(done)/elapsed.Seconds()),
+		)
+	}
+
+	return nil
+}
+
+// copyUsers copies rows into the users table. In upsert mode they are copied into
+// a temporary table first, since COPY cannot resolve conflicts.
+func copyUsers(ctx context.Context, pool *pgxpool.Pool, mode string, rows [][]any) error {
+	if mode == SeedModeTruncate {
+		_, err := pool.CopyFrom(ctx, pgx.Identifier{"users"}, userColumns, pgx.CopyFromRows(rows))
+		return err
+	}
+
+	return pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, createSeedUsersQuery); err != nil {
+			return fmt.Errorf("create temporary table: %w", err)
+		}
+		if _, err := tx.CopyFrom(ctx, pgx.Identifier{"seed_users"}, userColumns, pgx.CopyFromRows(rows)); err != nil {
+			return fmt.Errorf("copy: %w", err)
+		}
+		if _, err := tx.Exec(ctx, upsertSeedUsersQuery); err != nil {
+			return fmt.Errorf("upsert: %w", err)
+		}
+		return nil
+	})
+}
+
+// userGenerator generates realistic users from a deterministic random source.
+type userGenerator struct {
+	rng *rand.Rand
+	n   int
+}
+
+func newUserGenerator(seed uint64) *userGenerator {
+	return &userGenerator{
+		//nolint:gosec // Deterministic test data, not used for security.
+		rng: rand.New(rand.NewPCG(seed, seed)),
+	}
+}
+
+// next returns the values of the next user in the order of userColumns.
+func (g *userGenerator) next() []any {
+	g.n++
+
+	first := firstNames[g.rng.IntN(len(firstNames))]
+	last := lastNames[g.rng.IntN(len(lastNames))]
+	// The sequence number keeps the emails unique within a seed.
+	email := fmt.Sprintf("%s.%s.%d@%s",
+		strings.ToLower(first), strings.ToLower(last), g.n, emailDomains[g.rng.IntN(len(emailDomains))])
+
+	var id [16]byte
+	g.fill(id[:])
+	id[6] = id[6]&0x0f | 0x40 // version 4
+	id[8] = id[8]&0x3f | 0x80 // RFC 4122 variant
+
+	passwordHash := make([]byte, 32)
+	g.fill(passwordHash)
+
+	createdAt := seedEpoch.Add(-time.Duration(g.rng.Int64N(int64(365 * 24 * time.Hour))))
+	updatedAt := createdAt.Add(time.Duration(g.rng.Int64N(int64(seedEpoch.Sub(createdAt)) + 1)))
+
+	return []any{id, email, first + " " + last, passwordHash, createdAt, updatedAt}
+}
+
+func (g *userGenerator) fill(b []byte) {
+	for i := range b {
+		b[i] = byte(g.rng.Uint32())
+	}
+}
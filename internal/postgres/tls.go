@@ -0,0 +1,120 @@
+package postgres
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// tlsReloader re-reads the TLS certificate files when they change on disk,
+// so new connections pick up rotated certificates without a restart.
+type tlsReloader struct {
+	connString string
+	files      []string
+
+	mu     sync.Mutex
+	stamp  string
+	config *pgconn.Config
+}
+
+// newTLSReloader returns a reloader for the certificate files of connString, or nil if there are none.
+// The files are taken from the settings of connString, so certificates given in the URL are watched too.
+func newTLSReloader(connString string) (*tlsReloader, error) {
+	settings, err := parseDSN(connString)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, key := range []string{"sslrootcert", "sslcert", "sslkey"} {
+		if f := settings[key]; f != "" && f != sslRootCertSystem {
+			files = append(files, f)
+		}
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	return &tlsReloader{
+		connString: connString,
+		files:      files,
+	}, nil
+}
+
+// beforeConnect is a [pgxpool.Config.BeforeConnect] hook that applies the latest TLS configuration.
+func (r *tlsReloader) beforeConnect(_ context.Context, cc *pgx.ConnConfig) error {
+	parsed, err := r.current()
+	if err != nil {
+		return err
+	}
+
+	cc.TLSConfig = parsed.TLSConfig
+	cc.Fallbacks = parsed.Fallbacks
+
+	return nil
+}
+
+// current returns the connection config parsed from the certificate files as they are now.
+// While a rotation is in progress and the files cannot be parsed, the previous config is kept.
+func (r *tlsReloader) current() (*pgconn.Config, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stamp := r.filesStamp()
+	if r.config != nil && stamp == r.stamp {
+		return r.config, nil
+	}
+
+	parsed, err := pgconn.ParseConfig(r.connString)
+	if err != nil {
+		if r.config != nil {
+			return r.config, nil
+		}
+		return nil, fmt.Errorf("parse TLS config: %w", err)
+	}
+
+	r.stamp = stamp
+	r.config = parsed
+
+	return parsed, nil
+}
+
+// filesStamp identifies the current version of the certificate files by their size and modification time.
+func (r *tlsReloader) filesStamp() string {
+	var b strings.Builder
+	for _, f := range r.files {
+		fi, err := os.Stat(f)
+		if err != nil {
+			b.WriteString("missing;")
+			continue
+		}
+		fmt.Fprintf(&b, "%d:%s;", fi.Size(), fi.ModTime().Format(time.RFC3339Nano))
+	}
+
+	return b.String()
+}
+
+// tlsVersion returns the TLS protocol version negotiated by a connection of the pool,
+// e.g. "1.3", or an empty string if the connection is not encrypted.
+func tlsVersion(ctx context.Context, pool *pgxpool.Pool) (string, error) {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return "", fmt.Errorf("acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	tlsConn, ok := conn.Conn().PgConn().Conn().(*tls.Conn)
+	if !ok {
+		return "", nil
+	}
+
+	return strings.TrimPrefix(tls.VersionName(tlsConn.ConnectionState().Version), "TLS "), nil
+}
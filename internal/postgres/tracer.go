@@ -4,12 +4,17 @@ import (
 	"strings"
 
 	"github.com/exaring/otelpgx"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-func newTracer() *otelpgx.Tracer {
+// poolRoleKey is the span and metric attribute holding the role of the pool.
+const poolRoleKey = "db.pool.role"
+
+func newTracer(role string) *otelpgx.Tracer {
 	// Use global trace provider
 	return otelpgx.NewTracer(
 		otelpgx.WithSpanNameFunc(queryName),
+		otelpgx.WithAttributes(attribute.String(poolRoleKey, role)),
 	)
 }
 
@@ -0,0 +1,379 @@
+package postgres
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pressly/goose/v3"
+)
+
+// Kinds of drift between the embedded migrations and the database.
+const (
+	// DriftChecksumMismatch is an applied migration whose file changed after it was applied.
+	DriftChecksumMismatch = "checksum_mismatch"
+	// DriftChecksumMissing is an applied migration with no recorded checksum.
+	DriftChecksumMissing = "checksum_missing"
+	// DriftUnknownVersion is a version in the goose table with no embedded migration,
+	// e.g. applied by a newer release or by hand.
+	DriftUnknownVersion = "unknown_version"
+	// DriftSchemaMismatch is a table whose columns differ from the expected definition.
+	DriftSchemaMismatch = "schema_mismatch"
+)
+
+// checksumTable records the checksum of the file of every applied migration.
+// It is managed by the migrator next to the goose version table.
+const checksumTable = "goose_migration_checksums"
+
+const createChecksumTableQuery = `-- name: CreateMigrationChecksums :exec
+CREATE TABLE IF NOT EXISTS ` + checksumTable + ` (
+	version_id BIGINT PRIMARY KEY,
+	checksum TEXT NOT NULL,
+	recorded_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+)`
+
+const upsertChecksumQuery = `-- name: UpsertMigrationChecksum :exec
+INSERT INTO ` + checksumTable + ` (version_id, checksum) VALUES ($1, $2)
+ON CONFLICT (version_id) DO UPDATE SET checksum = EXCLUDED.checksum, recorded_at = NOW()`
+
+const insertMissingChecksumQuery = `-- name: InsertMissingMigrationChecksum :exec
+INSERT INTO ` + checksumTable + ` (version_id, checksum) VALUES ($1, $2)
+ON CONFLICT (version_id) DO NOTHING`
+
+const deleteChecksumQuery = `-- name: DeleteMigrationChecksum :exec
+DELETE FROM ` + checksumTable + ` WHERE version_id = $1`
+
+const checksumsQuery = `-- name: MigrationChecksums :many
+SELECT version_id, checksum FROM ` + checksumTable
+
+const tableExistsQuery = `-- name: TableExists :one
+SELECT to_regclass($1) IS NOT NULL`
+
+const appliedVersionsQuery = `-- name: AppliedMigrationVersions :many
+SELECT DISTINCT version_id FROM ` + goose.DefaultTablename + `
+WHERE is_applied AND version_id > 0
+ORDER BY version_id`
+
+const tableColumnsQuery = `-- name: TableColumns :many
+SELECT column_name, data_type, is_nullable = 'YES'
+FROM information_schema.columns
+WHERE table_schema = current_schema() AND table_name = $1
+ORDER BY ordinal_position`
+
+// tableDefinition is the expected definition of a table created by the migrations.
+type tableDefinition struct {
+	name    string
+	columns []columnDefinition
+}
+
+type columnDefinition struct {
+	name string
+	// dataType is the information_schema.columns.data_type of the column.
+	dataType string
+	nullable bool
+}
+
+// expectedSchema is the definition of the tables created by the embedded migrations.
+// Keep it in sync with the migration files.
+var expectedSchema = []tableDefinition{
+	{
+		name: "users",
+		columns: []columnDefinition{
+			{name: "id", dataType: "uuid"},
+			{name: "email", dataType: "text"},
+			{name: "name", dataType: "text"},
+			{name: "password_hash", dataType: "bytea"},
+			{name: "created_at", dataType: "timestamp with time zone"},
+			{name: "updated_at", dataType: "timestamp with time zone"},
+		},
+	},
+}
+
+// VerifyConfig configures the drift check of a service at startup.
+type VerifyConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Schema also compares the tables against their expected definition.
+	Schema bool `yaml:"schema"`
+	// FailOnDrift stops the service from starting when drift is found.
+	// Otherwise the drift is logged and reported by the readiness check.
+	FailOnDrift bool `yaml:"fail_on_drift"`
+}
+
+// VerifyOptions configures [Verify].
+type VerifyOptions struct {
+	// Schema also compares the tables in information_schema against their expected definition.
+	Schema bool
+}
+
+// DriftFinding is a single difference between the embedded migrations and the database.
+type DriftFinding struct {
+	Kind    string `json:"kind"`
+	Version int64  `json:"version,omitempty"`
+	Table   string `json:"table,omitempty"`
+	Message string `json:"message"`
+}
+
+// DriftReport is the result of [Verify].
+type DriftReport struct {
+	// Checked lists the checks that were run.
+	Checked  []string       `json:"checked"`
+	Findings []DriftFinding `json:"findings"`
+}
+
+// OK reports whether no drift was found.
+func (r *DriftReport) OK() bool {
+	return len(r.Findings) == 0
+}
+
+// Err returns an error summarizing the findings, or nil if no drift was found.
+func (r *DriftReport) Err() error {
+	if r.OK() {
+		return nil
+	}
+
+	msgs := make([]string, 0, len(r.Findings))
+	for _, f := range r.Findings {
+		msgs = append(msgs, f.Message)
+	}
+
+	return fmt.Errorf("schema drift detected: %s", strings.Join(msgs, "; "))
+}
+
+func (r *DriftReport) add(f DriftFinding) {
+	r.Findings = append(r.Findings, f)
+}
+
+// Verify compares the database of pool against the embedded migrations.
+// It reports applied migrations whose file changed since they were applied,
+// versions in the goose table that have no embedded migration and,
+// with opts.Schema, tables that differ from their expected definition.
+func Verify(ctx context.Context, pool *pgxpool.Pool, opts VerifyOptions) (*DriftReport, error) {
+	ctx, span := tracer.Start(ctx, "migrate.verify")
+	defer span.End()
+
+	report := &DriftReport{Findings: []DriftFinding{}}
+
+	if err := verifyMigrations(ctx, pool, report); err != nil {
+		return nil, err
+	}
+	if opts.Schema {
+		if err := verifySchema(ctx, pool, report); err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+func verifyMigrations(ctx context.Context, pool *pgxpool.Pool, report *DriftReport) error {
+	report.Checked = append(report.Checked, "checksums", "versions")
+
+	checksums, err := migrationChecksums()
+	if err != nil {
+		return err
+	}
+
+	applied, err := appliedVersions(ctx, pool)
+	if err != nil {
+		return err
+	}
+
+	recorded, err := recordedChecksums(ctx, pool)
+	if err != nil {
+		return err
+	}
+
+	for _, version := range applied {
+		want, ok := checksums[version]
+		if !ok {
+			report.add(DriftFinding{
+				Kind:    DriftUnknownVersion,
+				Version: version,
+				Message: fmt.Sprintf("version %d is applied but has no embedded migration", version),
+			})
+			continue
+		}
+
+		got, ok := recorded[version]
+		switch {
+		case !ok:
+			report.add(DriftFinding{
+				Kind:    DriftChecksumMissing,
+				Version: version,
+				Message: fmt.Sprintf("version %d is applied but its checksum was not recorded", version),
+			})
+		case got != want:
+			report.add(DriftFinding{
+				Kind:    DriftChecksumMismatch,
+				Version: version,
+				Message: fmt.Sprintf("migration %d changed after it was applied", version),
+			})
+		}
+	}
+
+	return nil
+}
+
+func verifySchema(ctx context.Context, pool *pgxpool.Pool, report *DriftReport) error {
+	report.Checked = append(report.Checked, "schema")
+
+	for _, table := range expectedSchema {
+		rows, err := pool.Query(ctx, tableColumnsQuery, table.name)
+		if err != nil {
+			return fmt.Errorf("query columns of %s: %w", table.name, err)
+		}
+		actual, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (columnDefinition, error) {
+			var c columnDefinition
+			err := row.Scan(&c.name, &c.dataType, &c.nullable)
+			return c, err
+		})
+		if err != nil {
+			return fmt.Errorf("query columns of %s: %w", table.name, err)
+		}
+
+		for _, diff := range diffColumns(table.columns, actual) {
+			report.add(DriftFinding{
+				Kind:    DriftSchemaMismatch,
+				Table:   table.name,
+				Message: fmt.Sprintf("table %s: %s", table.name, diff),
+			})
+		}
+	}
+
+	return nil
+}
+
+// diffColumns describes the differences between the expected and actual columns of a table.
+func diffColumns(expected, actual []columnDefinition) []string {
+	if len(actual) == 0 {
+		return []string{"does not exist"}
+	}
+
+	var diffs []string
+	for _, want := range expected {
+		i := slices.IndexFunc(actual, func(c columnDefinition) bool { return c.name == want.name })
+		if i < 0 {
+			diffs = append(diffs, fmt.Sprintf("column %s is missing", want.name))
+			continue
+		}
+
+		got := actual[i]
+		if got.dataType != want.dataType {
+			diffs = append(diffs, fmt.Sprintf("column %s has type %s, expected %s", want.name, got.dataType, want.dataType))
+		}
+		if got.nullable != want.nullable {
+			diffs = append(diffs, fmt.Sprintf("column %s has nullable %t, expected %t", want.name, got.nullable, want.nullable))
+		}
+	}
+	for _, got := range actual {
+		if !slices.ContainsFunc(expected, func(c columnDefinition) bool { return c.name == got.name }) {
+			diffs = append(diffs, fmt.Sprintf("column %s is unexpected", got.name))
+		}
+	}
+
+	return diffs
+}
+
+// migrationChecksums returns the checksums of the embedded migration files by version.
+func migrationChecksums() (map[int64]string, error) {
+	entries, err := fs.ReadDir(migrationFS, migrationDir)
+	if err != nil {
+		return nil, fmt.Errorf("read migrations: %w", err)
+	}
+
+	checksums := make(map[int64]string, len(entries))
+	for _, e := range entries {
+		version, err := goose.NumericComponent(e.Name())
+		if err != nil {
+			continue
+		}
+
+		checksum, err := migrationChecksum(migrationFS, path.Join(migrationDir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		checksums[version] = checksum
+	}
+
+	return checksums, nil
+}
+
+// migrationChecksum returns the SHA-256 checksum of the migration file at name in fsys.
+func migrationChecksum(fsys fs.FS, name string) (string, error) {
+	content, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return "", fmt.Errorf("read migration %s: %w", name, err)
+	}
+
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// appliedVersions returns the versions recorded in the goose table.
+// It returns no versions when no migration has been run yet.
+func appliedVersions(ctx context.Context, pool *pgxpool.Pool) ([]int64, error) {
+	exists, err := tableExists(ctx, pool, goose.DefaultTablename)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	rows, err := pool.Query(ctx, appliedVersionsQuery)
+	if err != nil {
+		return nil, fmt.Errorf("query applied versions: %w", err)
+	}
+	applied, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return nil, fmt.Errorf("query applied versions: %w", err)
+	}
+
+	return applied, nil
+}
+
+// recordedChecksums returns the checksums recorded when the migrations were applied.
+// It returns no checksums when the checksum table does not exist yet.
+func recordedChecksums(ctx context.Context, pool *pgxpool.Pool) (map[int64]string, error) {
+	exists, err := tableExists(ctx, pool, checksumTable)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return map[int64]string{}, nil
+	}
+
+	rows, err := pool.Query(ctx, checksumsQuery)
+	if err != nil {
+		return nil, fmt.Errorf("query checksums: %w", err)
+	}
+
+	recorded := map[int64]string{}
+	var (
+		version  int64
+		checksum string
+	)
+	_, err = pgx.ForEachRow(rows, []any{&version, &checksum}, func() error {
+		recorded[version] = checksum
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("query checksums: %w", err)
+	}
+
+	return recorded, nil
+}
+
+// tableExists reports whether table exists in the search path.
+func tableExists(ctx context.Context, pool *pgxpool.Pool, table string) (bool, error) {
+	var exists bool
+	if err := pool.QueryRow(ctx, tableExistsQuery, table).Scan(&exists); err != nil {
+		return false, fmt.Errorf("check table %s: %w", table, err)
+	}
+
+	return exists, nil
+}
@@ -0,0 +1,86 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	"github.com/prometheus/common/expfmt"
+
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/pkg/config"
+)
+
+// PushConfig configures pushing metrics of short-lived jobs, which cannot be scraped.
+type PushConfig struct {
+	// URL is the Pushgateway compatible endpoint to push to,
+	// e.g. http://victoriametrics:8428/api/v1/import/prometheus for VictoriaMetrics.
+	// If empty, metrics are not pushed.
+	URL string `yaml:"url"`
+	// Job is the value of the job label of the pushed metrics.
+	Job     string        `yaml:"job"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+func (c *PushConfig) Validate() error {
+	if c.URL == "" {
+		return nil
+	}
+
+	var errs []error
+
+	if u, err := url.Parse(c.URL); err != nil {
+		errs = append(errs, config.Field("url", err))
+	} else if u.Scheme != "http" && u.Scheme != "https" {
+		errs = append(errs, config.Fieldf("url", "must be an http or https URL"))
+	}
+	if c.Job == "" {
+		errs = append(errs, config.Fieldf("job", "is required when url is set"))
+	}
+	if c.Timeout <= 0 {
+		errs = append(errs, config.Fieldf("timeout", "must be greater than 0"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// PushMetrics pushes the metrics of gatherer to cfg.URL with the Pushgateway protocol.
+// It is a no-op when cfg.URL is empty.
+func PushMetrics(ctx context.Context, cfg PushConfig, gatherer prometheus.Gatherer) error {
+	if cfg.URL == "" {
+		return nil
+	}
+
+	// Add uses POST and the text format, which are accepted by both Pushgateway
+	// and the VictoriaMetrics import API.
+	err := push.New(cfg.URL, cfg.Job).
+		Gatherer(gatherer).
+		Client(&http.Client{Timeout: cfg.Timeout, Transport: noContentTransport{http.DefaultTransport}}).
+		Format(expfmt.NewFormat(expfmt.TypeTextPlain)).
+		AddContext(ctx)
+	if err != nil {
+		return fmt.Errorf("push metrics: %w", err)
+	}
+
+	return nil
+}
+
+// noContentTransport reports the 204 No Content responses of the VictoriaMetrics import API
+// as 200 OK, the only success statuses the push client accepts being 200 and 202.
+type noContentTransport struct {
+	next http.RoundTripper
+}
+
+func (t noContentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusNoContent {
+		resp.StatusCode = http.StatusOK
+		resp.Status = "200 OK"
+	}
+
+	return resp, err
+}
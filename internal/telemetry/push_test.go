@@ -0,0 +1,144 @@
+package telemetry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+)
+
+// migrationRegistry returns a registry with the metrics recorded by the migrate job.
+func migrationRegistry(t *testing.T) *prometheus.Registry {
+	t.Helper()
+
+	reg := prometheus.NewRegistry()
+	duration := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "migration_duration_seconds",
+	}, []string{"version", "direction"})
+	applied := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "migrations_applied_total",
+	}, []string{"direction"})
+	version := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "schema_version",
+	})
+	reg.MustRegister(duration, applied, version)
+
+	duration.WithLabelValues("20260101000000", "up").Set(0.25)
+	applied.WithLabelValues("up").Add(2)
+	version.Set(20260101000000)
+
+	return reg
+}
+
+func TestPushMetrics(t *testing.T) {
+	type request struct {
+		method   string
+		path     string
+		families map[string]*dto.MetricFamily
+	}
+	requests := make(chan request, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parser := expfmt.NewTextParser(model.UTF8Validation)
+		families, err := parser.TextToMetricFamilies(r.Body)
+		if err != nil {
+			t.Errorf("parse pushed metrics: %v", err)
+		}
+		requests <- request{method: r.Method, path: r.URL.Path, families: families}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	cfg := PushConfig{URL: srv.URL, Job: "migrate", Timeout: time.Second}
+	if err := PushMetrics(context.Background(), cfg, migrationRegistry(t)); err != nil {
+		t.Fatalf("PushMetrics() error = %v", err)
+	}
+
+	req := <-requests
+	if req.method != http.MethodPost {
+		t.Errorf("method = %s, want POST", req.method)
+	}
+	// The job label is given by the path, as in the Pushgateway protocol.
+	if want := "/metrics/job/migrate"; req.path != want {
+		t.Errorf("path = %s, want %s", req.path, want)
+	}
+
+	tests := []struct {
+		name   string
+		labels map[string]string
+		value  float64
+	}{
+		{name: "migration_duration_seconds", labels: map[string]string{"version": "20260101000000", "direction": "up"}, value: 0.25},
+		{name: "migrations_applied_total", labels: map[string]string{"direction": "up"}, value: 2},
+		{name: "schema_version", value: 20260101000000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			family, ok := req.families[tt.name]
+			if !ok {
+				t.Fatalf("%s was not pushed", tt.name)
+			}
+			if len(family.GetMetric()) != 1 {
+				t.Fatalf("%s has %d series, want 1", tt.name, len(family.GetMetric()))
+			}
+
+			metric := family.GetMetric()[0]
+			labels := make(map[string]string)
+			for _, l := range metric.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			for name, want := range tt.labels {
+				if labels[name] != want {
+					t.Errorf("%s label = %q, want %q", name, labels[name], want)
+				}
+			}
+
+			var value float64
+			if metric.GetGauge() != nil {
+				value = metric.GetGauge().GetValue()
+			} else {
+				value = metric.GetCounter().GetValue()
+			}
+			if value != tt.value {
+				t.Errorf("value = %v, want %v", value, tt.value)
+			}
+		})
+	}
+}
+
+func TestPushMetricsFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	cfg := PushConfig{URL: srv.URL, Job: "migrate", Timeout: time.Second}
+	if err := PushMetrics(context.Background(), cfg, migrationRegistry(t)); err == nil {
+		t.Fatal("PushMetrics() error = nil, want an error for a 503 response")
+	}
+}
+
+func TestPushMetricsTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	cfg := PushConfig{URL: srv.URL, Job: "migrate", Timeout: 50 * time.Millisecond}
+	if err := PushMetrics(context.Background(), cfg, migrationRegistry(t)); err == nil {
+		t.Fatal("PushMetrics() error = nil, want a timeout error")
+	}
+}
+
+func TestPushMetricsDisabled(t *testing.T) {
+	if err := PushMetrics(context.Background(), PushConfig{}, migrationRegistry(t)); err != nil {
+		t.Fatalf("PushMetrics() error = %v, want nil without url", err)
+	}
+}
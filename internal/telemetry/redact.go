@@ -0,0 +1,70 @@
+package telemetry
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/pkg/redact"
+)
+
+var _ sdktrace.SpanExporter = (*redactExporter)(nil)
+
+// redactExporter redacts the attributes of spans and their events before exporting them.
+type redactExporter struct {
+	sdktrace.SpanExporter
+	redactor *redact.Redactor
+}
+
+func (e *redactExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	redacted := make([]sdktrace.ReadOnlySpan, len(spans))
+	for i, span := range spans {
+		redacted[i] = &redactedSpan{ReadOnlySpan: span, redactor: e.redactor}
+	}
+
+	return e.SpanExporter.ExportSpans(ctx, redacted)
+}
+
+// redactedSpan is a span whose attributes and event attributes are redacted.
+type redactedSpan struct {
+	sdktrace.ReadOnlySpan
+	redactor *redact.Redactor
+}
+
+func (s *redactedSpan) Attributes() []attribute.KeyValue {
+	return s.attributes(s.ReadOnlySpan.Attributes())
+}
+
+func (s *redactedSpan) Events() []sdktrace.Event {
+	events := s.ReadOnlySpan.Events()
+	redacted := make([]sdktrace.Event, len(events))
+	for i, event := range events {
+		event.Attributes = s.attributes(event.Attributes)
+		redacted[i] = event
+	}
+
+	return redacted
+}
+
+func (s *redactedSpan) attributes(attrs []attribute.KeyValue) []attribute.KeyValue {
+	redacted := make([]attribute.KeyValue, len(attrs))
+	for i, kv := range attrs {
+		key := string(kv.Key)
+		switch {
+		case s.redactor.Sensitive(key):
+			kv.Value = attribute.StringValue(redact.Placeholder)
+		case kv.Value.Type() == attribute.STRING:
+			kv.Value = attribute.StringValue(s.redactor.String(key, kv.Value.AsString()))
+		case kv.Value.Type() == attribute.STRINGSLICE && s.redactor.IsEmail(key):
+			values := kv.Value.AsStringSlice()
+			for j, v := range values {
+				values[j] = s.redactor.Email(v)
+			}
+			kv.Value = attribute.StringSliceValue(values)
+		}
+		redacted[i] = kv
+	}
+
+	return redacted
+}
@@ -0,0 +1,58 @@
+package telemetry
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/pkg/redact"
+)
+
+func TestRedactExporter(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	redactor := redact.New(redact.Config{
+		Keys:      []string{"token", "authorization"},
+		EmailKeys: []string{"email"},
+		Email:     redact.EmailMask,
+	})
+	e := &redactExporter{SpanExporter: exporter, redactor: redactor}
+
+	attrs := []attribute.KeyValue{
+		attribute.String("http.request.header.authorization", "Bearer abc"),
+		attribute.Int("token.count", 3),
+		attribute.String("user.email", "john@example.com"),
+		attribute.StringSlice("user.emails", []string{"john@example.com", "jane@example.com"}),
+		attribute.String("url.path", "/users"),
+	}
+	want := []attribute.KeyValue{
+		attribute.String("http.request.header.authorization", redact.Placeholder),
+		attribute.String("token.count", redact.Placeholder),
+		attribute.String("user.email", "j***@example.com"),
+		attribute.StringSlice("user.emails", []string{"j***@example.com", "j***@example.com"}),
+		attribute.String("url.path", "/users"),
+	}
+	stubs := tracetest.SpanStubs{{
+		Name:       "span",
+		Attributes: attrs,
+		Events:     []sdktrace.Event{{Name: "event", Attributes: attrs}},
+	}}
+
+	if err := e.ExportSpans(context.Background(), stubs.Snapshots()); err != nil {
+		t.Fatalf("ExportSpans() error = %v", err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("exported %d spans, want 1", len(spans))
+	}
+	if got := spans[0].Attributes; !slices.Equal(got, want) {
+		t.Errorf("span attributes = %v, want %v", got, want)
+	}
+	if got := spans[0].Events[0].Attributes; !slices.Equal(got, want) {
+		t.Errorf("event attributes = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,42 @@
+package telemetry
+
+import (
+	"sync/atomic"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+var _ sdktrace.Sampler = (*ratioSampler)(nil)
+
+// sampler is the sampler installed by InitTracer.
+// Its ratio can be changed at runtime with SetTraceIDRatio.
+var sampler = newRatioSampler(1)
+
+// ratioSampler is a trace ID ratio based sampler whose ratio can be swapped atomically.
+type ratioSampler struct {
+	s atomic.Pointer[sdktrace.Sampler]
+}
+
+func newRatioSampler(ratio float64) *ratioSampler {
+	rs := &ratioSampler{}
+	rs.setRatio(ratio)
+	return rs
+}
+
+func (rs *ratioSampler) setRatio(ratio float64) {
+	s := sdktrace.TraceIDRatioBased(ratio)
+	rs.s.Store(&s)
+}
+
+func (rs *ratioSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	return (*rs.s.Load()).ShouldSample(p)
+}
+
+func (rs *ratioSampler) Description() string {
+	return (*rs.s.Load()).Description()
+}
+
+// SetTraceIDRatio changes the ratio of traces sampled by the tracer installed by InitTracer.
+func SetTraceIDRatio(ratio float64) {
+	sampler.setRatio(ratio)
+}
@@ -13,6 +13,9 @@ import (
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"google.golang.org/grpc/credentials"
+
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/pkg/config"
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/pkg/redact"
 )
 
 type Config struct {
@@ -20,12 +23,12 @@ type Config struct {
 	CollectorURL  string  `yaml:"collector_url"`
 	Insecure      bool    `yaml:"insecure"`
 	TraceIDRatio  float64 `yaml:"trace_id_ratio"`
-	CollectorAuth string  `yaml:"collector_auth"`
+	CollectorAuth string  `yaml:"collector_auth" secret:"true"`
 }
 
 func (c *Config) Validate() error {
 	if c.TraceIDRatio < 0 || c.TraceIDRatio > 1 {
-		return fmt.Errorf("trace ID ratio must be between 0 and 1")
+		return config.Fieldf("trace_id_ratio", "must be between 0 and 1")
 	}
 
 	return nil
@@ -35,7 +38,8 @@ type CleanupFunc func(context.Context) error
 
 // InitTracer initializes the OpenTelemetry tracer.
 // Should be called at the start of the application to get the tracer set globally.
-func InitTracer(ctx context.Context, cfg Config) (CleanupFunc, error) {
+// The attributes of the exported spans are redacted with redactor.
+func InitTracer(ctx context.Context, cfg Config, redactor *redact.Redactor) (CleanupFunc, error) {
 	if cfg.CollectorURL == "" {
 		// no-op
 		return func(context.Context) error {
@@ -78,17 +82,18 @@ func InitTracer(ctx context.Context, cfg Config) (CleanupFunc, error) {
 		return nil, fmt.Errorf("set resources: %w", err)
 	}
 
-	otel.SetTracerProvider(
-		sdktrace.NewTracerProvider(
-			sdktrace.WithSampler(sdktrace.TraceIDRatioBased(cfg.TraceIDRatio)),
-			sdktrace.WithBatcher(
-				exporter,
-				sdktrace.WithMaxQueueSize(sdktrace.DefaultMaxQueueSize*10),
-				sdktrace.WithMaxExportBatchSize(sdktrace.DefaultMaxExportBatchSize*10),
-			),
-			sdktrace.WithResource(resources),
+	sampler.setRatio(cfg.TraceIDRatio)
+
+	tracerProvider := sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(sampler),
+		sdktrace.WithBatcher(
+			&redactExporter{SpanExporter: exporter, redactor: redactor},
+			sdktrace.WithMaxQueueSize(sdktrace.DefaultMaxQueueSize*10),
+			sdktrace.WithMaxExportBatchSize(sdktrace.DefaultMaxExportBatchSize*10),
 		),
+		sdktrace.WithResource(resources),
 	)
+	otel.SetTracerProvider(tracerProvider)
 
 	otel.SetTextMapPropagator(propagation.TraceContext{})
 
@@ -96,8 +101,10 @@ func InitTracer(ctx context.Context, cfg Config) (CleanupFunc, error) {
 		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 		defer cancel()
 
-		if err := exporter.Shutdown(ctx); err != nil {
-			return fmt.Errorf("shutdown OpenTelemetry exporter: %w", err)
+		// Shutting down the provider flushes the queued spans before shutting down the exporter,
+		// so spans of short-lived programs are not lost.
+		if err := tracerProvider.Shutdown(ctx); err != nil {
+			return fmt.Errorf("shutdown OpenTelemetry tracer provider: %w", err)
 		}
 
 		return nil
@@ -0,0 +1,213 @@
+// Package config loads application configuration from an embedded default,
+// command line flags, an optional YAML file and prefixed environment variables.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/knadh/koanf/parsers/yaml"
+	"github.com/knadh/koanf/providers/env/v2"
+	"github.com/knadh/koanf/providers/file"
+	"github.com/knadh/koanf/providers/posflag"
+	"github.com/knadh/koanf/providers/rawbytes"
+	"github.com/knadh/koanf/v2"
+	flag "github.com/spf13/pflag"
+)
+
+// ErrExit is returned by [Loader.Load] when a command flag such as --print-config
+// has been handled and the program should exit successfully.
+var ErrExit = errors.New("config: exit requested")
+
+// ErrHelp is returned by [Loader.Load] when --help was given and the usage has been printed.
+var ErrHelp = flag.ErrHelp
+
+const (
+	flagConfig       = "config"
+	flagPrintConfig  = "print-config"
+	flagValidateOnly = "validate-only"
+	flagPrintSchema  = "print-schema"
+)
+
+// Options configures a [Loader].
+type Options struct {
+	// Name is the program name used for the flag set and the JSON Schema title.
+	Name string
+	// EnvPrefix is the prefix of the environment variables to load, e.g. "API_".
+	EnvPrefix string
+	// Defaults is the embedded default YAML configuration.
+	Defaults []byte
+	// Usage is printed before the flags by --help, e.g. to describe subcommands.
+	Usage string
+}
+
+// Loader loads a configuration of type T.
+// Use [NewLoader] to create a new instance of Loader.
+type Loader[T any] struct {
+	opts  Options
+	flags *flag.FlagSet
+
+	// values is the flattened key/value map of the last successful Load.
+	values map[string]any
+}
+
+// NewLoader initializes a Loader instance.
+func NewLoader[T any](opts Options) *Loader[T] {
+	f := flag.NewFlagSet(opts.Name, flag.ContinueOnError)
+	f.Usage = func() {
+		if opts.Usage != "" {
+			fmt.Println(opts.Usage)
+		}
+		fmt.Println(f.FlagUsages())
+	}
+
+	f.String(flagConfig, "", "path to config file, if not provided, the default configuration will be used")
+	f.Bool(flagPrintConfig, false, "print the effective configuration with secrets redacted and exit")
+	f.Bool(flagValidateOnly, false, "validate the configuration and exit")
+	f.Bool(flagPrintSchema, false, "print the JSON Schema of the configuration file and exit")
+
+	return &Loader[T]{
+		opts:  opts,
+		flags: f,
+	}
+}
+
+// Flags returns the flag set parsed by Load, so callers can register their own flags.
+func (l *Loader[T]) Flags() *flag.FlagSet {
+	return l.flags
+}
+
+// ConfigFile returns the path of the config file given with --config.
+func (l *Loader[T]) ConfigFile() string {
+	path, _ := l.flags.GetString(flagConfig)
+	return path
+}
+
+// Load parses args and loads and merges configuration from the embedded default,
+// flags, optional external config file, and environment variables (in this order).
+// Secrets can also be read from files named by a *_file key, see [SecretKeys].
+// The result is validated with [Validate].
+//
+// If a command flag was given, Load performs the command and returns [ErrExit].
+// If --help was given, it prints the usage and returns [ErrHelp].
+func (l *Loader[T]) Load(args []string) (*T, error) {
+	if err := l.flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil, ErrHelp
+		}
+		return nil, fmt.Errorf("parse flags: %w", err)
+	}
+
+	if printSchema, _ := l.flags.GetBool(flagPrintSchema); printSchema {
+		b, err := Schema[T](l.opts.Name)
+		if err != nil {
+			return nil, fmt.Errorf("generate schema: %w", err)
+		}
+		fmt.Println(string(b))
+		return nil, ErrExit
+	}
+
+	cfg, k, err := l.load()
+	if err != nil {
+		return nil, err
+	}
+
+	if printConfig, _ := l.flags.GetBool(flagPrintConfig); printConfig {
+		b, err := redacted[T](k).Marshal(yaml.Parser())
+		if err != nil {
+			return nil, fmt.Errorf("marshal config: %w", err)
+		}
+		fmt.Print(string(b))
+		return nil, ErrExit
+	}
+
+	if err := Validate(cfg); err != nil {
+		return nil, err
+	}
+
+	if validateOnly, _ := l.flags.GetBool(flagValidateOnly); validateOnly {
+		fmt.Println("configuration is valid")
+		return nil, ErrExit
+	}
+
+	l.values = k.All()
+
+	return cfg, nil
+}
+
+// Reload loads the configuration again from all sources using the flags given to Load,
+// and validates it. It also returns the flattened key/value map the configuration was
+// decoded from.
+func (l *Loader[T]) Reload() (*T, map[string]any, error) {
+	cfg, k, err := l.load()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := Validate(cfg); err != nil {
+		return nil, nil, err
+	}
+
+	return cfg, k.All(), nil
+}
+
+func (l *Loader[T]) load() (*T, *koanf.Koanf, error) {
+	k := koanf.New(".")
+
+	if err := k.Load(rawbytes.Provider(l.opts.Defaults), yaml.Parser()); err != nil {
+		return nil, nil, fmt.Errorf("load default config: %w", err)
+	}
+
+	// Only flags named after a config key, e.g. --http.port, override the config.
+	if err := k.Load(posflag.ProviderWithFlag(l.flags, ".", k, func(f *flag.Flag) (string, any) {
+		if !k.Exists(f.Name) {
+			return "", nil
+		}
+		return f.Name, posflag.FlagVal(l.flags, f)
+	}), nil); err != nil {
+		return nil, nil, fmt.Errorf("load flags: %w", err)
+	}
+
+	if path := l.ConfigFile(); path != "" {
+		if err := k.Load(file.Provider(path), yaml.Parser()); err != nil {
+			return nil, nil, fmt.Errorf("load config file: %w", err)
+		}
+	}
+
+	// PREFIX_foo__bar -> foo.bar (double underscore becomes dot for nested config)
+	prefix := l.opts.EnvPrefix
+	if err := k.Load(env.Provider(".", env.Opt{
+		Prefix: prefix,
+		TransformFunc: func(k, v string) (string, any) {
+			key := strings.ToLower(strings.TrimPrefix(k, prefix))
+			key = strings.ReplaceAll(key, "__", ".")
+			return key, v
+		},
+	}), nil); err != nil {
+		return nil, nil, fmt.Errorf("load env: %w", err)
+	}
+
+	if err := loadSecretFiles[T](k); err != nil {
+		return nil, nil, fmt.Errorf("load secret files: %w", err)
+	}
+
+	var cfg T
+	if err := k.UnmarshalWithConf("", &cfg, koanf.UnmarshalConf{
+		Tag: "yaml",
+		DecoderConfig: &mapstructure.DecoderConfig{
+			// Comma separated env values such as API_HTTP__CORS__ALLOWED_ORIGINS are decoded into slices.
+			DecodeHook: mapstructure.ComposeDecodeHookFunc(
+				mapstructure.StringToTimeDurationHookFunc(),
+				mapstructure.StringToSliceHookFunc(","),
+				mapstructure.TextUnmarshallerHookFunc(),
+			),
+			WeaklyTypedInput: true,
+		},
+	}); err != nil {
+		return nil, nil, fmt.Errorf("unmarshal config: %w", err)
+	}
+
+	return &cfg, k, nil
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const schemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// durationPattern matches the values accepted by [time.ParseDuration].
+const durationPattern = `^-?([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$|^0$`
+
+var (
+	durationType        = reflect.TypeFor[time.Duration]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// Schema generates a JSON Schema for the YAML configuration file of T from its `yaml` struct tags.
+//
+// Fields can be further described with the following struct tags:
+//   - `enum:"a,b,c"` restricts the allowed values.
+//   - `secret:"true"` marks the value as write-only and allows a *_file companion key.
+func Schema[T any](title string) ([]byte, error) {
+	s := schemaFor(reflect.TypeFor[T]())
+	s["$schema"] = schemaDialect
+	s["title"] = title
+
+	return json.MarshalIndent(s, "", "  ")
+}
+
+func schemaFor(t reflect.Type) map[string]any {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == durationType:
+		return map[string]any{"type": "string", "pattern": durationPattern}
+	case isTextType(t):
+		return map[string]any{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaFor(t.Elem())}
+	case reflect.Struct:
+		props := map[string]any{}
+		for i := range t.NumField() {
+			f := t.Field(i)
+			name := fieldKey(f)
+			if name == "" {
+				continue
+			}
+
+			fs := schemaFor(f.Type)
+			if enum := f.Tag.Get("enum"); enum != "" {
+				fs["enum"] = strings.Split(enum, ",")
+			}
+			props[name] = fs
+			if f.Tag.Get("secret") == "true" {
+				fs["writeOnly"] = true
+				props[name+secretFileSuffix] = map[string]any{"type": "string"}
+			}
+		}
+		return map[string]any{
+			"type":                 "object",
+			"properties":           props,
+			"additionalProperties": false,
+		}
+	default:
+		return map[string]any{}
+	}
+}
+
+// isTextType reports whether values of t are decoded from text.
+func isTextType(t reflect.Type) bool {
+	return t.Implements(textUnmarshalerType) || reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
@@ -0,0 +1,126 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/knadh/koanf/v2"
+)
+
+const (
+	// redactedValue replaces secret values in printed configuration.
+	redactedValue = "******"
+
+	// secretFileSuffix is appended to the key of a secret to read its value from a file instead,
+	// e.g. postgres.password_file in YAML or API_POSTGRES__PASSWORD_FILE in the environment.
+	secretFileSuffix = "_file"
+)
+
+// SecretKeys returns the key paths of the fields of T tagged with `secret:"true"`.
+// Secrets are redacted when the configuration is printed, and each of them can be
+// read from a file named by the same key with a _file suffix.
+func SecretKeys[T any]() []string {
+	var keys []string
+	walkFields(reflect.TypeFor[T](), "", func(key string, f reflect.StructField) {
+		if f.Tag.Get("secret") == "true" {
+			keys = append(keys, key)
+		}
+	})
+
+	return keys
+}
+
+// loadSecretFiles replaces the value of every secret key of T that has a
+// companion *_file key with the contents of that file.
+// The file takes precedence over a value given directly.
+func loadSecretFiles[T any](k *koanf.Koanf) error {
+	for _, key := range SecretKeys[T]() {
+		fileKey := key + secretFileSuffix
+		if !k.Exists(fileKey) {
+			continue
+		}
+
+		path := k.String(fileKey)
+		k.Delete(fileKey)
+		if path == "" {
+			continue
+		}
+
+		//nolint:gosec // reading the file named by the configuration is the point
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", fileKey, err)
+		}
+		if err := k.Set(key, strings.TrimRight(string(b), "\r\n")); err != nil {
+			return fmt.Errorf("set %s: %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+// redacted returns a copy of k with the values of all secret keys of T redacted.
+func redacted[T any](k *koanf.Koanf) *koanf.Koanf {
+	out := k.Copy()
+	for _, key := range SecretKeys[T]() {
+		if out.String(key) != "" {
+			_ = out.Set(key, redactedValue)
+		}
+	}
+
+	return out
+}
+
+// walkFields calls fn for every field of the struct type t that is mapped to a config key,
+// descending into nested structs.
+func walkFields(t reflect.Type, prefix string, fn func(key string, f reflect.StructField)) {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name := fieldKey(f)
+		if name == "" {
+			continue
+		}
+
+		key := prefix + name
+		fn(key, f)
+
+		if isSection(f.Type) {
+			walkFields(f.Type, key+".", fn)
+		}
+	}
+}
+
+// fieldKey returns the config key of a struct field, or an empty string if the field is not mapped.
+func fieldKey(f reflect.StructField) string {
+	if !f.IsExported() {
+		return ""
+	}
+
+	name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		name = strings.ToLower(f.Name)
+	}
+
+	return name
+}
+
+// isSection reports whether t is a nested config section rather than a scalar value.
+func isSection(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	return t.Kind() == reflect.Struct && !isTextType(t)
+}
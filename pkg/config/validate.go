@@ -0,0 +1,130 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Validator is implemented by configuration sections that can check their own values.
+// Validate should only check the fields of the section itself; nested sections are
+// validated separately by [Validate].
+type Validator interface {
+	Validate() error
+}
+
+// FieldError reports an invalid value at a config key path.
+type FieldError struct {
+	Key string
+	Err error
+}
+
+// Field returns an error reporting that the value at key, relative to the section
+// being validated, is invalid.
+func Field(key string, err error) error {
+	return &FieldError{Key: key, Err: err}
+}
+
+// Fieldf is like [Field] but formats the message.
+func Fieldf(key, format string, args ...any) error {
+	return Field(key, fmt.Errorf(format, args...))
+}
+
+func (e *FieldError) Error() string {
+	if e.Key == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s: %v", e.Key, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError aggregates all the problems found in a configuration.
+type ValidationError struct {
+	Errors []*FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, fe := range e.Errors {
+		msgs = append(msgs, fe.Error())
+	}
+	return fmt.Sprintf("invalid configuration:\n  %s", strings.Join(msgs, "\n  "))
+}
+
+// Validate calls Validate on cfg and every nested section implementing [Validator]
+// and returns all the problems found as a [*ValidationError] with full key paths,
+// e.g. "postgres.host: is required".
+func Validate(cfg any) error {
+	var errs []*FieldError
+	validateValue(reflect.ValueOf(cfg), "", &errs)
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return &ValidationError{Errors: errs}
+}
+
+func validateValue(v reflect.Value, key string, errs *[]*FieldError) {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return
+	}
+
+	if v.CanAddr() {
+		if validator, ok := v.Addr().Interface().(Validator); ok {
+			collectFieldErrors(validator.Validate(), key, errs)
+		}
+	} else if validator, ok := v.Interface().(Validator); ok {
+		collectFieldErrors(validator.Validate(), key, errs)
+	}
+
+	t := v.Type()
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name := fieldKey(f)
+		if name == "" || !isSection(f.Type) {
+			continue
+		}
+		validateValue(v.Field(i), joinKey(key, name), errs)
+	}
+}
+
+// collectFieldErrors flattens err, which may be joined with [errors.Join],
+// into field errors prefixed with key.
+func collectFieldErrors(err error, key string, errs *[]*FieldError) {
+	if err == nil {
+		return
+	}
+
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			collectFieldErrors(e, key, errs)
+		}
+		return
+	}
+
+	if fe, ok := err.(*FieldError); ok {
+		collectFieldErrors(fe.Err, joinKey(key, fe.Key), errs)
+		return
+	}
+
+	*errs = append(*errs, &FieldError{Key: key, Err: err})
+}
+
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	if key == "" {
+		return prefix
+	}
+	return prefix + "." + key
+}
@@ -0,0 +1,179 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/knadh/koanf/providers/file"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var configReloadsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "config_reloads_total",
+	Help: "Total number of config file reloads by result",
+}, []string{"result"})
+
+const (
+	reloadResultApplied   = "applied"
+	reloadResultUnchanged = "unchanged"
+	reloadResultRejected  = "rejected"
+	reloadResultFailed    = "failed"
+)
+
+// Watcher watches the config file given to a [Loader] and applies changes to
+// reload-safe keys to its subscribers. Changes to any other key are rejected
+// and logged with a diff.
+// Use [NewWatcher] to create a new instance of Watcher.
+type Watcher[T any] struct {
+	loader     *Loader[T]
+	logger     *slog.Logger
+	provider   *file.File
+	reloadable []string
+	secrets    []string
+
+	mu          sync.Mutex
+	values      map[string]any
+	subscribers []func(cfg *T) error
+}
+
+// NewWatcher initializes a Watcher for the config file loaded by loader.
+// reloadableKeys lists the config keys (and key prefixes) that can be changed
+// without restarting the process. It returns nil if no config file was given.
+func NewWatcher[T any](loader *Loader[T], logger *slog.Logger, reloadableKeys ...string) *Watcher[T] {
+	path := loader.ConfigFile()
+	if path == "" {
+		return nil
+	}
+
+	return &Watcher[T]{
+		loader:     loader,
+		logger:     logger.With(slog.String("config_file", path)),
+		provider:   file.Provider(path),
+		reloadable: reloadableKeys,
+		secrets:    SecretKeys[T](),
+		values:     loader.values,
+	}
+}
+
+// Subscribe registers fn to be called with the new config after every applied reload.
+func (w *Watcher[T]) Subscribe(fn func(cfg *T) error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.subscribers = append(w.subscribers, fn)
+}
+
+// Watch starts watching the config file in the background.
+func (w *Watcher[T]) Watch() error {
+	return w.provider.Watch(func(_ any, err error) {
+		if err != nil {
+			w.logger.Error("error watching config file", slog.Any("error", err))
+			return
+		}
+
+		w.reload()
+	})
+}
+
+// Close stops watching the config file.
+func (w *Watcher[T]) Close() error {
+	return w.provider.Unwatch()
+}
+
+func (w *Watcher[T]) reload() {
+	cfg, values, err := w.loader.Reload()
+	if err != nil {
+		configReloadsTotal.WithLabelValues(reloadResultFailed).Inc()
+		w.logger.Error("error reloading config", slog.Any("error", err))
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	changed := changedKeys(w.values, values)
+	if len(changed) == 0 {
+		configReloadsTotal.WithLabelValues(reloadResultUnchanged).Inc()
+		return
+	}
+
+	var rejected []string
+	for _, key := range changed {
+		if !w.isReloadable(key) {
+			rejected = append(rejected, key)
+		}
+	}
+	if len(rejected) > 0 {
+		configReloadsTotal.WithLabelValues(reloadResultRejected).Inc()
+		w.logger.Warn("config reload rejected, non-reloadable settings changed",
+			slog.Any("diff", w.diff(rejected, w.values, values)),
+		)
+		return
+	}
+
+	diff := w.diff(changed, w.values, values)
+	w.values = values
+	var errs []error
+	for _, fn := range w.subscribers {
+		if err := fn(cfg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	// The subscribers that succeeded keep the new config, so the values are not rolled back.
+	if err := errors.Join(errs...); err != nil {
+		configReloadsTotal.WithLabelValues(reloadResultFailed).Inc()
+		w.logger.Error("error applying reloaded config", slog.Any("diff", diff), slog.Any("error", err))
+		return
+	}
+
+	configReloadsTotal.WithLabelValues(reloadResultApplied).Inc()
+	w.logger.Info("config reloaded", slog.Any("diff", diff))
+}
+
+// changedKeys returns the sorted keys whose values differ between old and new.
+func changedKeys(old, new map[string]any) []string {
+	var keys []string
+	for key, v := range new {
+		if ov, ok := old[key]; !ok || !reflect.DeepEqual(ov, v) {
+			keys = append(keys, key)
+		}
+	}
+	for key := range old {
+		if _, ok := new[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+
+	return keys
+}
+
+// diff renders the old and new values of keys, hiding secrets.
+func (w *Watcher[T]) diff(keys []string, old, new map[string]any) []string {
+	diff := make([]string, 0, len(keys))
+	for _, key := range keys {
+		ov, nv := old[key], new[key]
+		if slices.Contains(w.secrets, key) {
+			ov, nv = redactedValue, redactedValue
+		}
+		diff = append(diff, fmt.Sprintf("%s: %v -> %v", key, ov, nv))
+	}
+
+	return diff
+}
+
+func (w *Watcher[T]) isReloadable(key string) bool {
+	for _, k := range w.reloadable {
+		if key == k || strings.HasPrefix(key, k+".") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,185 @@
+// Package redact removes secrets and personal data from logged and traced values.
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tuanvumaihuynh/victoria-o11y-lab/pkg/config"
+)
+
+// Placeholder replaces redacted values.
+const Placeholder = "[REDACTED]"
+
+// EmailMode is how email addresses are redacted.
+type EmailMode string
+
+const (
+	// EmailKeep logs email addresses as they are.
+	EmailKeep EmailMode = "keep"
+	// EmailMask keeps the first letter of the local part and the domain, e.g. j***@example.com.
+	EmailMask EmailMode = "mask"
+	// EmailHash replaces email addresses with a hash, so records of the same address
+	// can still be correlated.
+	EmailHash EmailMode = "hash"
+	// EmailRedact replaces email addresses with the Placeholder.
+	EmailRedact EmailMode = "redact"
+)
+
+// Config holds the redaction rules.
+type Config struct {
+	// Keys are case-insensitive substrings of the keys whose values are replaced
+	// with the Placeholder, e.g. "token" matches access_token and X-Csrf-Token.
+	Keys []string `yaml:"keys"`
+	// EmailKeys are case-insensitive substrings of the keys whose values are email addresses.
+	EmailKeys []string `yaml:"email_keys"`
+	// Email is how email addresses are redacted.
+	Email EmailMode `yaml:"email" enum:"keep,mask,hash,redact"`
+	// HashKey is the HMAC key of hashed email addresses. Without it, hashes of common
+	// addresses can be reversed by hashing candidates.
+	HashKey string `yaml:"hash_key" secret:"true"`
+}
+
+func (c *Config) Validate() error {
+	switch c.Email {
+	case EmailKeep, EmailMask, EmailHash, EmailRedact:
+		return nil
+	default:
+		return config.Fieldf("email", "must be one of %s, %s, %s or %s", EmailKeep, EmailMask, EmailHash, EmailRedact)
+	}
+}
+
+// Redactor redacts values by their key, by the `redact` struct tag of their fields
+// and query parameters by their name.
+// Use [New] to create a new instance of Redactor.
+type Redactor struct {
+	keys      []string
+	emailKeys []string
+	email     EmailMode
+	hashKey   []byte
+}
+
+// New initializes a Redactor with the rules of cfg.
+func New(cfg Config) *Redactor {
+	lower := func(keys []string) []string {
+		out := make([]string, 0, len(keys))
+		for _, k := range keys {
+			if k != "" {
+				out = append(out, strings.ToLower(k))
+			}
+		}
+		return out
+	}
+
+	return &Redactor{
+		keys:      lower(cfg.Keys),
+		emailKeys: lower(cfg.EmailKeys),
+		email:     cfg.Email,
+		hashKey:   []byte(cfg.HashKey),
+	}
+}
+
+// Sensitive reports whether the value of key must be replaced entirely.
+func (r *Redactor) Sensitive(key string) bool {
+	return containsAny(strings.ToLower(key), r.keys)
+}
+
+// IsEmail reports whether the value of key is an email address.
+func (r *Redactor) IsEmail(key string) bool {
+	return containsAny(strings.ToLower(key), r.emailKeys)
+}
+
+// String returns value redacted according to its key.
+func (r *Redactor) String(key, value string) string {
+	switch {
+	case r.Sensitive(key):
+		return Placeholder
+	case r.IsEmail(key):
+		return r.Email(value)
+	default:
+		return value
+	}
+}
+
+// Email returns the email address redacted according to the email mode.
+func (r *Redactor) Email(email string) string {
+	switch r.email {
+	case EmailKeep:
+		return email
+	case EmailMask:
+		local, domain, ok := strings.Cut(email, "@")
+		if !ok || local == "" {
+			return Placeholder
+		}
+		first, _ := utf8.DecodeRuneInString(local)
+		return string(first) + "***@" + domain
+	case EmailHash:
+		var sum []byte
+		if len(r.hashKey) > 0 {
+			mac := hmac.New(sha256.New, r.hashKey)
+			mac.Write([]byte(strings.ToLower(email)))
+			sum = mac.Sum(nil)
+		} else {
+			s := sha256.Sum256([]byte(strings.ToLower(email)))
+			sum = s[:]
+		}
+		return "sha256:" + hex.EncodeToString(sum[:8])
+	default:
+		return Placeholder
+	}
+}
+
+// Query returns query with the values of sensitive parameters redacted.
+func (r *Redactor) Query(query url.Values) url.Values {
+	redacted := make(url.Values, len(query))
+	for key, values := range query {
+		redacted[key] = make([]string, len(values))
+		for i, v := range values {
+			redacted[key][i] = r.String(key, v)
+		}
+	}
+
+	return redacted
+}
+
+// encodeQuery encodes query like [url.Values.Encode], but leaves the Placeholder readable.
+func encodeQuery(query url.Values) string {
+	return strings.ReplaceAll(query.Encode(), url.QueryEscape(Placeholder), Placeholder)
+}
+
+// Any returns v redacted according to key, and the fields of structs tagged with `redact`.
+// Values of other types are returned unchanged.
+func (r *Redactor) Any(key string, v any) any {
+	if r.Sensitive(key) {
+		return Placeholder
+	}
+
+	switch v := v.(type) {
+	case string:
+		return r.String(key, v)
+	case url.Values:
+		return encodeQuery(r.Query(v))
+	case fmt.Stringer, error:
+		return v
+	default:
+		if redacted, ok := r.Struct(v); ok {
+			return redacted
+		}
+		return v
+	}
+}
+
+func containsAny(s string, substrings []string) bool {
+	for _, sub := range substrings {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+
+	return false
+}
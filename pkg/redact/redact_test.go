@@ -0,0 +1,134 @@
+package redact
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestEmail(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   Config
+		email string
+		want  string
+	}{
+		{name: "keep", cfg: Config{Email: EmailKeep}, email: "john@example.com", want: "john@example.com"},
+		{name: "mask", cfg: Config{Email: EmailMask}, email: "john@example.com", want: "j***@example.com"},
+		{name: "mask multibyte first character", cfg: Config{Email: EmailMask}, email: "élodie@example.com", want: "é***@example.com"},
+		{name: "mask without local part", cfg: Config{Email: EmailMask}, email: "@example.com", want: Placeholder},
+		{name: "mask not an email", cfg: Config{Email: EmailMask}, email: "john", want: Placeholder},
+		{name: "redact", cfg: Config{Email: EmailRedact}, email: "john@example.com", want: Placeholder},
+		{name: "hash", cfg: Config{Email: EmailHash}, email: "john@example.com", want: "sha256:855f96e983f1f8e8"},
+		{name: "hash ignores case", cfg: Config{Email: EmailHash}, email: "John@Example.com", want: "sha256:855f96e983f1f8e8"},
+		{name: "hash with key", cfg: Config{Email: EmailHash, HashKey: "key"}, email: "john@example.com", want: "sha256:da94f7c6b7019314"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := New(tt.cfg).Email(tt.email); got != tt.want {
+				t.Errorf("Email(%q) = %q, want %q", tt.email, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	r := New(Config{Keys: []string{"token", "Password", ""}, EmailKeys: []string{"email"}, Email: EmailMask})
+
+	tests := []struct {
+		key, value, want string
+	}{
+		{key: "access_token", value: "abc", want: Placeholder},
+		{key: "X-Csrf-Token", value: "abc", want: Placeholder},
+		{key: "password", value: "password123", want: Placeholder},
+		{key: "user_email", value: "john@example.com", want: "j***@example.com"},
+		{key: "name", value: "John", want: "John"},
+	}
+	for _, tt := range tests {
+		if got := r.String(tt.key, tt.value); got != tt.want {
+			t.Errorf("String(%q, %q) = %q, want %q", tt.key, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestQuery(t *testing.T) {
+	r := New(Config{Keys: []string{"token"}, EmailKeys: []string{"email"}, Email: EmailMask})
+
+	query := url.Values{
+		"access_token": {"abc", "def"},
+		"email":        {"john@example.com"},
+		"limit":        {"20"},
+	}
+	got := r.Query(query)
+
+	want := url.Values{
+		"access_token": {Placeholder, Placeholder},
+		"email":        {"j***@example.com"},
+		"limit":        {"20"},
+	}
+	if got.Encode() != want.Encode() {
+		t.Errorf("Query() = %v, want %v", got, want)
+	}
+	if query.Get("access_token") != "abc" {
+		t.Error("Query() modified its argument")
+	}
+}
+
+func TestAny(t *testing.T) {
+	r := New(Config{Keys: []string{"token"}, EmailKeys: []string{"email"}, Email: EmailMask})
+
+	type body struct {
+		Password string `json:"password" redact:"true"`
+	}
+	tests := []struct {
+		name string
+		key  string
+		v    any
+		want any
+	}{
+		{name: "sensitive key", key: "token", v: 42, want: Placeholder},
+		{name: "email", key: "email", v: "john@example.com", want: "j***@example.com"},
+		{
+			name: "query",
+			key:  "query",
+			v:    url.Values{"token": {"abc"}, "email": {"john@example.com"}},
+			want: "email=j%2A%2A%2A%40example.com&token=" + Placeholder,
+		},
+		{name: "error", key: "error", v: errors.New("token abc"), want: errors.New("token abc")},
+		{name: "other", key: "count", v: 42, want: 42},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := r.Any(tt.key, tt.v)
+			if err, ok := tt.want.(error); ok {
+				if got.(error).Error() != err.Error() {
+					t.Errorf("Any() = %v, want %v", got, tt.want)
+				}
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Any() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	got, ok := r.Any("body", body{Password: "password123"}).(map[string]any)
+	if !ok || got["password"] != Placeholder {
+		t.Errorf("Any() of a tagged struct = %v, want the password redacted", got)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	for _, mode := range []EmailMode{EmailKeep, EmailMask, EmailHash, EmailRedact} {
+		cfg := Config{Email: mode}
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("Validate() with email %s error = %v", mode, err)
+		}
+	}
+
+	cfg := Config{Email: "obfuscate"}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "email:") {
+		t.Errorf("Validate() error = %v, want an error on email", err)
+	}
+}
@@ -0,0 +1,179 @@
+package redact
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Values of the `redact` struct tag.
+const (
+	// TagSecret replaces the value of the field with the Placeholder: `redact:"true"`.
+	TagSecret = "true"
+	// TagEmail redacts the value of the field according to the email mode: `redact:"email"`.
+	TagEmail = "email"
+)
+
+// taggedTypes caches whether a struct type has a field tagged with `redact`,
+// directly or in a nested struct.
+var taggedTypes sync.Map // map[reflect.Type]bool
+
+// Field is a field of a struct redacted by [Redactor.Fields].
+type Field struct {
+	// Name is the JSON name of the field.
+	Name string
+	// Value is the redacted value, or the []Field of a nested struct with tagged fields.
+	Value any
+}
+
+// Fields returns the fields of the struct v, or of the struct it points to, in order and
+// named by their JSON names, with the fields tagged with `redact` redacted.
+// It reports false if v is not a struct with tagged fields.
+//
+//	type CreateUserRequestBody struct {
+//		Email    string `json:"email" redact:"email"`
+//		Password string `json:"password" redact:"true"`
+//	}
+func (r *Redactor) Fields(v any) ([]Field, bool) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil, false
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct || !hasTaggedFields(rv.Type()) {
+		return nil, false
+	}
+
+	return r.structFields(rv), true
+}
+
+// Struct is like [Redactor.Fields] but returns the fields as a map, e.g. to be encoded as JSON.
+func (r *Redactor) Struct(v any) (map[string]any, bool) {
+	fields, ok := r.Fields(v)
+	if !ok {
+		return nil, false
+	}
+
+	return fieldsMap(fields), true
+}
+
+func fieldsMap(fields []Field) map[string]any {
+	m := make(map[string]any, len(fields))
+	for _, f := range fields {
+		if nested, ok := f.Value.([]Field); ok {
+			m[f.Name] = fieldsMap(nested)
+		} else {
+			m[f.Name] = f.Value
+		}
+	}
+
+	return m
+}
+
+func (r *Redactor) structFields(rv reflect.Value) []Field {
+	t := rv.Type()
+	fields := make([]Field, 0, t.NumField())
+
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name, omitEmpty, ok := jsonName(f)
+		if !ok {
+			continue
+		}
+
+		fv := rv.Field(i)
+		if omitEmpty && fv.IsZero() {
+			continue
+		}
+
+		var value any
+		switch f.Tag.Get("redact") {
+		case TagSecret:
+			value = Placeholder
+		case TagEmail:
+			if fv.Kind() == reflect.String {
+				value = r.Email(fv.String())
+			} else {
+				value = Placeholder
+			}
+		default:
+			if nested, ok := r.Fields(fv.Interface()); ok {
+				value = nested
+			} else {
+				value = fv.Interface()
+			}
+		}
+		fields = append(fields, Field{Name: name, Value: value})
+	}
+
+	return fields
+}
+
+// jsonName returns the JSON name of the exported field f and whether it is omitted when empty.
+func jsonName(f reflect.StructField) (name string, omitEmpty, ok bool) {
+	if !f.IsExported() {
+		return "", false, false
+	}
+
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "", false, false
+	}
+
+	name, opts, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = f.Name
+	}
+
+	return name, strings.Contains(opts, "omitempty"), true
+}
+
+// hasTaggedFields reports whether the struct type t has a field tagged with `redact`,
+// directly or in a nested struct. Only the final answer is cached, so concurrent callers
+// never read a partial one.
+func hasTaggedFields(t reflect.Type) bool {
+	if tagged, ok := taggedTypes.Load(t); ok {
+		return tagged.(bool)
+	}
+
+	tagged := structHasTaggedFields(t, make(map[reflect.Type]bool))
+	taggedTypes.Store(t, tagged)
+
+	return tagged
+}
+
+// structHasTaggedFields is hasTaggedFields without caching. visiting holds the types
+// being checked up the stack, so recursive types terminate. The answers for nested types
+// are not cached, since they may be partial when a cycle was cut short.
+func structHasTaggedFields(t reflect.Type, visiting map[reflect.Type]bool) bool {
+	if tagged, ok := taggedTypes.Load(t); ok {
+		return tagged.(bool)
+	}
+	if visiting[t] {
+		return false
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		if f.Tag.Get("redact") != "" {
+			return true
+		}
+
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct && structHasTaggedFields(ft, visiting) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,212 @@
+package redact
+
+import (
+	"reflect"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+type address struct {
+	City  string `json:"city"`
+	Email string `json:"email" redact:"email"`
+}
+
+type account struct {
+	ID       int    `json:"id"`
+	Password string `json:"password" redact:"true"`
+	Email    string `json:"email" redact:"email"`
+	PIN      int    `json:"pin" redact:"email"`
+	Nickname string `json:"nickname,omitempty"`
+	Internal string `json:"-"`
+	NoTag    string
+	Home     address  `json:"home"`
+	Work     *address `json:"work"`
+	Previous *address `json:"previous"`
+	secret   string
+}
+
+// node is a recursive type with a tagged field.
+type node struct {
+	Name   string `json:"name"`
+	Secret string `json:"secret" redact:"true"`
+	Next   *node  `json:"next"`
+}
+
+// plainNode is a recursive type without tagged fields.
+type plainNode struct {
+	Name string     `json:"name"`
+	Next *plainNode `json:"next"`
+}
+
+// cycleA and cycleB form a cycle, with the tagged field reachable only through cycleB.
+type cycleA struct {
+	B *cycleB `json:"b"`
+}
+
+type cycleB struct {
+	A      *cycleA `json:"a"`
+	Secret string  `json:"secret" redact:"true"`
+}
+
+// cycleC and cycleD form a cycle, with the tagged field reachable only through cycleC.
+// cycleD is checked first by the tests, so its answer depends on cycleC, still being checked.
+type cycleC struct {
+	D      *cycleD `json:"d"`
+	Secret string  `json:"secret" redact:"true"`
+}
+
+type cycleD struct {
+	C *cycleC `json:"c"`
+}
+
+func TestFields(t *testing.T) {
+	r := New(Config{Email: EmailMask})
+
+	acc := account{
+		ID:       1,
+		Password: "password123",
+		Email:    "john@example.com",
+		PIN:      1234,
+		Internal: "internal",
+		NoTag:    "no tag",
+		Home:     address{City: "Paris", Email: "home@example.com"},
+		Work:     &address{City: "Lyon", Email: "work@example.com"},
+		secret:   "secret",
+	}
+	want := map[string]any{
+		"id":       1,
+		"password": Placeholder,
+		"email":    "j***@example.com",
+		"pin":      Placeholder,
+		"NoTag":    "no tag",
+		"home":     map[string]any{"city": "Paris", "email": "h***@example.com"},
+		"work":     map[string]any{"city": "Lyon", "email": "w***@example.com"},
+		"previous": (*address)(nil),
+	}
+
+	tests := []struct {
+		name string
+		v    any
+		want map[string]any
+		ok   bool
+	}{
+		{name: "struct", v: acc, want: want, ok: true},
+		{name: "pointer", v: &acc, want: want, ok: true},
+		{name: "pointer to pointer", v: new(&acc), want: want, ok: true},
+		{name: "nil pointer", v: (*account)(nil)},
+		{name: "untagged struct", v: struct{ Name string }{Name: "John"}},
+		{name: "not a struct", v: "john@example.com"},
+		{
+			name: "recursive type",
+			v:    node{Name: "a", Secret: "s1", Next: &node{Name: "b", Secret: "s2"}},
+			want: map[string]any{
+				"name":   "a",
+				"secret": Placeholder,
+				"next":   map[string]any{"name": "b", "secret": Placeholder, "next": (*node)(nil)},
+			},
+			ok: true,
+		},
+		{name: "recursive type without tags", v: plainNode{Name: "a", Next: &plainNode{Name: "b"}}},
+		{name: "cycle tagged through another type", v: cycleD{C: &cycleC{Secret: "s"}}, want: map[string]any{
+			"c": map[string]any{"d": (*cycleD)(nil), "secret": Placeholder},
+		}, ok: true},
+		{name: "cycle tagged in another type", v: cycleA{B: &cycleB{Secret: "s"}}, want: map[string]any{
+			"b": map[string]any{"a": (*cycleA)(nil), "secret": Placeholder},
+		}, ok: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := r.Struct(tt.v)
+			if ok != tt.ok {
+				t.Fatalf("Struct() ok = %v, want %v", ok, tt.ok)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Struct() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFieldsOrder(t *testing.T) {
+	r := New(Config{Email: EmailRedact})
+
+	fields, ok := r.Fields(address{City: "Paris", Email: "john@example.com"})
+	if !ok {
+		t.Fatal("Fields() ok = false, want true")
+	}
+	want := []Field{{Name: "city", Value: "Paris"}, {Name: "email", Value: Placeholder}}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("Fields() = %v, want %v", fields, want)
+	}
+}
+
+func TestHasTaggedFieldsRecursive(t *testing.T) {
+	tests := []struct {
+		t    reflect.Type
+		want bool
+	}{
+		// cycleD is checked before cycleC is cached.
+		{t: reflect.TypeFor[cycleD](), want: true},
+		{t: reflect.TypeFor[cycleC](), want: true},
+		{t: reflect.TypeFor[cycleA](), want: true},
+		{t: reflect.TypeFor[cycleB](), want: true},
+		{t: reflect.TypeFor[node](), want: true},
+		{t: reflect.TypeFor[plainNode](), want: false},
+	}
+	for _, tt := range tests {
+		if got := hasTaggedFields(tt.t); got != tt.want {
+			t.Errorf("hasTaggedFields(%s) = %v, want %v", tt.t, got, tt.want)
+		}
+	}
+}
+
+// TestFieldsConcurrentFirstUse redacts structs of types never used before from several
+// goroutines at once. Each iteration creates a new type, so its first use is concurrent.
+func TestFieldsConcurrentFirstUse(t *testing.T) {
+	r := New(Config{Email: EmailMask})
+
+	const iterations, goroutines = 100, 8
+	// The goroutines run in parallel even on a single CPU.
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(goroutines))
+
+	want := map[string]any{"inner": map[string]any{"password": Placeholder}}
+	for i := range iterations {
+		// The field names make the types new. The untagged fields checked before the
+		// tagged one widen the window in which the answer is not known yet.
+		fields := make([]reflect.StructField, 0, 101)
+		for j := range 100 {
+			fields = append(fields, reflect.StructField{
+				Name: "F" + strconv.Itoa(i) + "_" + strconv.Itoa(j), Type: reflect.TypeFor[int](), Tag: `json:"-"`,
+			})
+		}
+		inner := reflect.StructOf(append(fields, reflect.StructField{
+			Name: "Password", Type: reflect.TypeFor[string](), Tag: `json:"password" redact:"true"`,
+		}))
+		typ := reflect.StructOf(append(fields, reflect.StructField{
+			Name: "Inner", Type: inner, Tag: `json:"inner"`,
+		}))
+		v := reflect.New(typ).Elem()
+		v.FieldByName("Inner").FieldByName("Password").SetString("password123")
+		user := v.Interface()
+
+		var wg sync.WaitGroup
+		start := make(chan struct{})
+		results := make([]map[string]any, goroutines)
+		for g := range goroutines {
+			wg.Go(func() {
+				<-start
+				results[g], _ = r.Struct(user)
+			})
+		}
+		close(start)
+		wg.Wait()
+
+		for g, got := range results {
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("iteration %d, goroutine %d: Struct() = %v, want %v", i, g, got, want)
+			}
+		}
+	}
+}
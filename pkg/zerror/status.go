@@ -3,22 +3,52 @@ package zerror
 type Status string
 
 const (
-	StatusUnknown             Status = "Unknown"
-	StatusUnauthorized        Status = "Unauthorized"
-	StatusForbidden           Status = "Forbidden"
-	StatusNotFound            Status = "Not found"
-	StatusUnprocessableEntity Status = "Unprocessable entity"
-	StatusConflict            Status = "Conflict"
-	StatusTooManyRequests     Status = "Too many requests"
-	StatusBadRequest          Status = "Bad request"
-	StatusValidationFailed    Status = "Validation failed"
-	StatusInternalServerError Status = "Internal server error"
-	StatusTimeout             Status = "Timeout"
-	StatusNotImplemented      Status = "Not implemented"
-	StatusBadGateway          Status = "Bad gateway"
-	StatusServiceUnavailable  Status = "Service unavailable"
+	StatusUnknown              Status = "Unknown"
+	StatusUnauthorized         Status = "Unauthorized"
+	StatusForbidden            Status = "Forbidden"
+	StatusNotFound             Status = "Not found"
+	StatusUnprocessableEntity  Status = "Unprocessable entity"
+	StatusConflict             Status = "Conflict"
+	StatusTooManyRequests      Status = "Too many requests"
+	StatusBadRequest           Status = "Bad request"
+	StatusValidationFailed     Status = "Validation failed"
+	StatusInternalServerError  Status = "Internal server error"
+	StatusTimeout              Status = "Timeout"
+	StatusNotImplemented       Status = "Not implemented"
+	StatusBadGateway           Status = "Bad gateway"
+	StatusServiceUnavailable   Status = "Service unavailable"
+	StatusContentTooLarge      Status = "Content too large"
+	StatusRequestTimeout       Status = "Request timeout"
+	StatusUnsupportedMediaType Status = "Unsupported media type"
+	StatusPreconditionFailed   Status = "Precondition failed"
+	StatusPreconditionRequired Status = "Precondition required"
 )
 
 func (s Status) String() string {
 	return string(s)
 }
+
+// Statuses returns all the defined statuses.
+func Statuses() []Status {
+	return []Status{
+		StatusUnknown,
+		StatusUnauthorized,
+		StatusForbidden,
+		StatusNotFound,
+		StatusUnprocessableEntity,
+		StatusConflict,
+		StatusTooManyRequests,
+		StatusBadRequest,
+		StatusValidationFailed,
+		StatusInternalServerError,
+		StatusTimeout,
+		StatusNotImplemented,
+		StatusBadGateway,
+		StatusServiceUnavailable,
+		StatusContentTooLarge,
+		StatusRequestTimeout,
+		StatusUnsupportedMediaType,
+		StatusPreconditionFailed,
+		StatusPreconditionRequired,
+	}
+}
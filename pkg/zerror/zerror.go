@@ -2,15 +2,34 @@ package zerror
 
 import (
 	"fmt"
+	"log/slog"
+	"maps"
+	"runtime"
+	"slices"
+	"strings"
 )
 
+// maxStackDepth is the maximum number of frames captured by [WithStack].
+const maxStackDepth = 32
+
 // ZError represents the base error structure.
 // Use [NewZError] to create a new instance of ZError.
 type ZError struct {
-	parent error
-	status Status
-	code   string
-	msg    string
+	parent    error
+	status    Status
+	code      string
+	msg       string
+	details   []Detail
+	metadata  map[string]any
+	stack     []uintptr
+	retryable bool
+}
+
+// Detail describes why the value of a field is invalid.
+type Detail struct {
+	// Field is the location of the field, e.g. body.email.
+	Field   string
+	Message string
 }
 
 // NewZError initializes a ZError instance.
@@ -38,6 +57,37 @@ func WithMsg(e ZError, msg string) ZError {
 	return e
 }
 
+// WithDetails creates a new ZError with details appended to the existing ones.
+func WithDetails(e ZError, details ...Detail) ZError {
+	e.details = append(slices.Clip(e.details), details...)
+	return e
+}
+
+// WithMetadata creates a new ZError with the key/value pair added to its metadata.
+// Metadata is logged with the error but not returned to clients.
+func WithMetadata(e ZError, key string, value any) ZError {
+	metadata := make(map[string]any, len(e.metadata)+1)
+	maps.Copy(metadata, e.metadata)
+	metadata[key] = value
+	e.metadata = metadata
+	return e
+}
+
+// WithStack creates a new ZError with the stack of the caller captured.
+// Predefined errors are created at init, so their stack is captured where they are returned.
+func WithStack(e ZError) ZError {
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(2, pcs)
+	e.stack = pcs[:n]
+	return e
+}
+
+// WithRetryable creates a new ZError marked as retryable or not.
+func WithRetryable(e ZError, retryable bool) ZError {
+	e.retryable = retryable
+	return e
+}
+
 func (e ZError) Error() string {
 	if e.parent != nil {
 		return fmt.Sprintf("Code=%s, Msg=%s, Parent=(%v)", e.code, e.msg, e.parent)
@@ -49,6 +99,47 @@ func (e *ZError) Unwrap() error {
 	return e.parent
 }
 
+// Is reports whether target is a *ZError with the same code, so errors.Is matches
+// the predefined errors regardless of the message, details or parent.
+func (e *ZError) Is(target error) bool {
+	t, ok := target.(*ZError)
+	return ok && t != nil && t.code == e.code
+}
+
+// LogValue implements slog.LogValuer, logging the error as a group of its fields.
+func (e ZError) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.String("code", e.code),
+		slog.String("status", e.status.String()),
+		slog.String("msg", e.msg),
+	}
+	if len(e.details) > 0 {
+		details := make([]slog.Attr, len(e.details))
+		for i, d := range e.details {
+			details[i] = slog.String(d.Field, d.Message)
+		}
+		attrs = append(attrs, slog.Attr{Key: "details", Value: slog.GroupValue(details...)})
+	}
+	if len(e.metadata) > 0 {
+		metadata := make([]slog.Attr, 0, len(e.metadata))
+		for _, key := range slices.Sorted(maps.Keys(e.metadata)) {
+			metadata = append(metadata, slog.Any(key, e.metadata[key]))
+		}
+		attrs = append(attrs, slog.Attr{Key: "metadata", Value: slog.GroupValue(metadata...)})
+	}
+	if e.retryable {
+		attrs = append(attrs, slog.Bool("retryable", true))
+	}
+	if e.parent != nil {
+		attrs = append(attrs, slog.String("parent", e.parent.Error()))
+	}
+	if len(e.stack) > 0 {
+		attrs = append(attrs, slog.String("stack", e.StackTrace()))
+	}
+
+	return slog.GroupValue(attrs...)
+}
+
 func (e ZError) Status() Status {
 	return e.status
 }
@@ -65,6 +156,45 @@ func (e ZError) Parent() error {
 	return e.parent
 }
 
+// Details returns the field-level details of the error.
+func (e ZError) Details() []Detail {
+	return slices.Clone(e.details)
+}
+
+// Metadata returns the metadata of the error.
+func (e ZError) Metadata() map[string]any {
+	return maps.Clone(e.metadata)
+}
+
+// Retryable reports whether the operation that failed can be retried.
+func (e ZError) Retryable() bool {
+	return e.retryable
+}
+
+// Stack returns the program counters captured by [WithStack], or nil.
+func (e ZError) Stack() []uintptr {
+	return slices.Clone(e.stack)
+}
+
+// StackTrace formats the stack captured by [WithStack] with one "function file:line" per line.
+func (e ZError) StackTrace() string {
+	if len(e.stack) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	frames := runtime.CallersFrames(e.stack)
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(&b, "%s %s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
 func NewUnauthorized(code, msg string) *ZError {
 	return NewZError(nil, StatusUnauthorized, code, msg)
 }
@@ -116,3 +246,23 @@ func NewBadGateway(code, msg string) *ZError {
 func NewServiceUnavailable(code, msg string) *ZError {
 	return NewZError(nil, StatusServiceUnavailable, code, msg)
 }
+
+func NewContentTooLarge(code, msg string) *ZError {
+	return NewZError(nil, StatusContentTooLarge, code, msg)
+}
+
+func NewRequestTimeout(code, msg string) *ZError {
+	return NewZError(nil, StatusRequestTimeout, code, msg)
+}
+
+func NewUnsupportedMediaType(code, msg string) *ZError {
+	return NewZError(nil, StatusUnsupportedMediaType, code, msg)
+}
+
+func NewPreconditionFailed(code, msg string) *ZError {
+	return NewZError(nil, StatusPreconditionFailed, code, msg)
+}
+
+func NewPreconditionRequired(code, msg string) *ZError {
+	return NewZError(nil, StatusPreconditionRequired, code, msg)
+}